@@ -0,0 +1,525 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gookit/color"
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// bruFile are the blocks of a .bru file by their name (e.g. meta,
+// headers or body:json). Their lines don't have the indentation of the
+// block.
+type bruFile map[string][]string
+
+// bruKeyValue is a line of a dictionary block. Disabled lines start
+// with ~.
+type bruKeyValue struct {
+	Key      string
+	Value    string
+	Disabled bool
+}
+
+var bruBlock = regexp.MustCompile(`^([A-Za-z0-9:_\-]+)\s*([{\[])\s*$`)
+
+// ParseBru reads the blocks of a .bru file.
+func ParseBru(buf []byte) (bruFile, error) {
+	f := bruFile{}
+	lines := strings.Split(strings.ReplaceAll(string(buf), "\r\n", "\n"), "\n")
+	for x := 0; x < len(lines); x++ {
+		if strings.TrimSpace(lines[x]) == "" {
+			continue
+		}
+		m := bruBlock.FindStringSubmatch(lines[x])
+		if m == nil {
+			return nil, fmt.Errorf("line %v: expected a block, got '%v'", x+1, lines[x])
+		}
+		end := "}"
+		if m[2] == "[" {
+			end = "]"
+		}
+		block := []string{}
+		for x++; x < len(lines) && strings.TrimRight(lines[x], " \t") != end; x++ {
+			block = append(block, strings.TrimPrefix(lines[x], "  "))
+		}
+		if x == len(lines) {
+			return nil, fmt.Errorf("block %v isn't closed", m[1])
+		}
+		f[m[1]] = block
+	}
+	return f, nil
+}
+
+// Dict is the dictionary in the block.
+func (f bruFile) Dict(name string) []bruKeyValue {
+	kvs := []bruKeyValue{}
+	for _, line := range f[name] {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		kv := bruKeyValue{}
+		if strings.HasPrefix(line, "~") {
+			kv.Disabled, line = true, line[1:]
+		}
+		parts := strings.SplitN(line, ":", 2)
+		kv.Key = strings.TrimSpace(parts[0])
+		if len(parts) == 2 {
+			kv.Value = strings.TrimSpace(parts[1])
+		}
+		kvs = append(kvs, kv)
+	}
+	return kvs
+}
+
+// Get is the value of the key in the dictionary block.
+func (f bruFile) Get(name, key string) string {
+	for _, kv := range f.Dict(name) {
+		if kv.Key == key && !kv.Disabled {
+			return kv.Value
+		}
+	}
+	return ""
+}
+
+// Text is the text in the block.
+func (f bruFile) Text(name string) string {
+	return strings.TrimSpace(strings.Join(f[name], "\n"))
+}
+
+// brunoFolder is what requests inherit from the collection and their
+// folders.
+type brunoFolder struct {
+	Auth    bruFile
+	Headers []bruKeyValue
+}
+
+// brunoImport is the result of converting a Bruno collection.
+type brunoImport struct {
+	Folders      []string
+	Requests     map[string][]NamedRequest
+	Environments map[string]Environment
+	Variables    map[string]string
+	Warnings     []string
+
+	root string
+}
+
+func (b *brunoImport) warn(where, format string, a ...interface{}) {
+	b.Warnings = append(b.Warnings, where+": "+fmt.Sprintf(format, a...))
+}
+
+var (
+	bruFileValue   = regexp.MustCompile(`^@file\((.*?)\)`)
+	bruContentType = regexp.MustCompile(`@contentType\((.*?)\)`)
+	bruPathParam   = regexp.MustCompile(`/:([A-Za-z0-9_\-]+)`)
+)
+
+// brunoContentTypes are the content types of the text bodies.
+var brunoContentTypes = map[string]string{
+	"json":   "application/json",
+	"text":   "text/plain",
+	"xml":    "application/xml",
+	"sparql": "application/sparql-query",
+}
+
+// ParseBruno converts the requests (.bru files) and environments (in
+// the environments folder) of the Bruno collection in root. Folders of
+// the collection become folders of the config.
+func ParseBruno(root string) (*brunoImport, error) {
+	if _, err := os.Stat(filepath.Join(root, "bruno.json")); err != nil {
+		return nil, fmt.Errorf("%v isn't a Bruno collection: %v", root, err)
+	}
+	b := &brunoImport{
+		Requests:     map[string][]NamedRequest{},
+		Environments: map[string]Environment{},
+		Variables:    map[string]string{},
+		root:         root,
+	}
+
+	collection, err := b.read(filepath.Join(root, "collection.bru"))
+	if err != nil {
+		return nil, err
+	}
+	if err := b.folder(root, "", b.settings("collection", collection, brunoFolder{})); err != nil {
+		return nil, err
+	}
+	if err := b.environments(filepath.Join(root, "environments")); err != nil {
+		return nil, err
+	}
+
+	// Collection and folder variables don't exist in aa, so they are
+	// added to the environments that don't set them.
+	if len(b.Variables) > 0 && len(b.Environments) == 0 {
+		name := "bruno"
+		if buf, err := ioutil.ReadFile(filepath.Join(root, "bruno.json")); err == nil {
+			info := struct{ Name string }{}
+			if json.Unmarshal(buf, &info) == nil && cleanName(info.Name) != "" {
+				name = cleanName(info.Name)
+			}
+		}
+		b.Environments[name] = Environment{}
+		b.Warnings = append(b.Warnings, fmt.Sprintf("collection variables imported as the '%v' environment", name))
+	}
+	for _, env := range b.Environments {
+		for k, v := range b.Variables {
+			if _, ok := env[k]; !ok {
+				env[k] = v
+			}
+		}
+	}
+	return b, nil
+}
+
+// read parses the .bru file. Files that don't exist have no blocks.
+func (b *brunoImport) read(path string) (bruFile, error) {
+	buf, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return bruFile{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	f, err := ParseBru(buf)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %v: %v", path, err)
+	}
+	return f, nil
+}
+
+// settings adds the headers and auth of the collection or folder to
+// what it inherited.
+func (b *brunoImport) settings(where string, f bruFile, parent brunoFolder) brunoFolder {
+	s := brunoFolder{Auth: parent.Auth, Headers: append(f.Dict("headers"), parent.Headers...)}
+	if mode := f.Get("auth", "mode"); mode != "" && mode != "inherit" {
+		s.Auth = f
+	}
+	for _, kv := range f.Dict("vars:pre-request") {
+		if kv.Disabled {
+			continue
+		}
+		// Variables are shared by every request, so a folder can't
+		// have its own value.
+		v := b.vars(where, kv.Value)
+		if old, ok := b.Variables[kv.Key]; ok && old != v {
+			b.warn(where, "variable '%v' is '%v' elsewhere; '%v' is used everywhere", kv.Key, old, v)
+		}
+		b.Variables[kv.Key] = v
+	}
+	for _, block := range []string{"script:pre-request", "script:post-response", "tests"} {
+		if f.Text(block) != "" {
+			b.warn(where, "%v not imported", block)
+		}
+	}
+	return s
+}
+
+// folder adds the requests in the folder of the collection and its sub
+// folders in the order of their sequence numbers.
+func (b *brunoImport) folder(dir, folder string, parent brunoFolder) error {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	type request struct {
+		seq int
+		r   NamedRequest
+	}
+	requests := []request{}
+	for _, info := range infos {
+		path := filepath.Join(dir, info.Name())
+		where := strings.TrimPrefix(folder+"/"+info.Name(), "/")
+		if info.IsDir() {
+			if strings.HasPrefix(info.Name(), ".") || info.Name() == "node_modules" || (folder == "" && info.Name() == "environments") {
+				continue
+			}
+			f, err := b.read(filepath.Join(path, "folder.bru"))
+			if err != nil {
+				return err
+			}
+			sub := strings.TrimPrefix(folder+"/"+cleanName(info.Name()), "/")
+			if err := b.folder(path, sub, b.settings(where, f, parent)); err != nil {
+				return err
+			}
+			continue
+		}
+		if filepath.Ext(info.Name()) != ".bru" || info.Name() == "folder.bru" || info.Name() == "collection.bru" {
+			continue
+		}
+		f, err := b.read(path)
+		if err != nil {
+			return err
+		}
+		r, ok := b.request(where, f, parent)
+		if !ok {
+			continue
+		}
+		name := cleanName(f.Get("meta", "name"))
+		if name == "" {
+			name = cleanName(strings.TrimSuffix(info.Name(), ".bru"))
+		}
+		seq, _ := strconv.Atoi(f.Get("meta", "seq"))
+		requests = append(requests, request{seq, NamedRequest{Name: name, Request: r}})
+	}
+
+	sort.SliceStable(requests, func(x, y int) bool { return requests[x].seq < requests[y].seq })
+	for _, r := range requests {
+		if _, ok := b.Requests[folder]; !ok {
+			b.Folders = append(b.Folders, folder)
+		}
+		b.Requests[folder] = append(b.Requests[folder], r.r)
+	}
+	return nil
+}
+
+// request converts the request in the .bru file. Files that aren't
+// HTTP or GraphQL requests aren't requests.
+func (b *brunoImport) request(where string, f bruFile, parent brunoFolder) (Request, bool) {
+	if t := f.Get("meta", "type"); t != "" && t != "http" && t != "graphql" {
+		b.warn(where, "%v requests not imported", t)
+		return Request{}, false
+	}
+	r := Request{
+		Description:    f.Text("docs"),
+		Headers:        map[string]string{},
+		Authentication: map[string]string{},
+		Query:          map[string]string{},
+	}
+	for method := range httpMethods {
+		if _, ok := f[strings.ToLower(method)]; ok {
+			r.Method = method
+		}
+	}
+	if r.Method == "" {
+		return Request{}, false
+	}
+	block := strings.ToLower(r.Method)
+
+	// The query parameters are already in the URL.
+	r.URL = b.vars(where, f.Get(block, "url"))
+	for _, kv := range f.Dict("params:query") {
+		if kv.Disabled {
+			b.warn(where, "disabled query parameter '%v' not imported", kv.Key)
+		}
+	}
+	params := map[string]string{}
+	for _, kv := range f.Dict("params:path") {
+		params[kv.Key] = b.vars(where, kv.Value)
+	}
+	r.URL = bruPathParam.ReplaceAllStringFunc(r.URL, func(m string) string {
+		if v, ok := params[m[2:]]; ok {
+			return "/" + v
+		}
+		b.warn(where, "path parameter '%v' has no value", m[2:])
+		return m
+	})
+
+	for _, kv := range append(f.Dict("headers"), parent.Headers...) {
+		if kv.Disabled {
+			b.warn(where, "disabled header '%v' not imported", kv.Key)
+			continue
+		}
+		if _, ok := r.Headers[kv.Key]; !ok {
+			r.Headers[kv.Key] = b.vars(where, kv.Value)
+		}
+	}
+
+	auth := f
+	mode := f.Get(block, "auth")
+	if mode == "inherit" && parent.Auth != nil {
+		auth, mode = parent.Auth, parent.Auth.Get("auth", "mode")
+	}
+	b.auth(where, &r, mode, auth)
+	b.body(where, &r, f.Get(block, "body"), f)
+
+	for _, name := range []string{"vars:pre-request", "vars:post-response", "assert"} {
+		if len(f.Dict(name)) > 0 {
+			b.warn(where, "%v not imported", name)
+		}
+	}
+	for _, name := range []string{"script:pre-request", "script:post-response", "tests"} {
+		if f.Text(name) != "" {
+			b.warn(where, "%v not imported", name)
+		}
+	}
+	return r, true
+}
+
+func (b *brunoImport) auth(where string, r *Request, mode string, f bruFile) {
+	switch mode {
+	case "", "none", "inherit":
+	case "bearer":
+		r.Authentication["type"] = "bearer"
+		r.Authentication["token"] = b.vars(where, f.Get("auth:bearer", "token"))
+	case "basic":
+		r.Authentication["type"] = "basic"
+		r.Authentication["username"] = b.vars(where, f.Get("auth:basic", "username"))
+		r.Authentication["password"] = b.vars(where, f.Get("auth:basic", "password"))
+	case "apikey":
+		k, v := b.vars(where, f.Get("auth:apikey", "key")), b.vars(where, f.Get("auth:apikey", "value"))
+		if f.Get("auth:apikey", "placement") == "queryparams" {
+			r.Query[k] = v
+		} else {
+			r.Headers[k] = v
+		}
+	default:
+		b.warn(where, "%v authentication not imported", mode)
+	}
+}
+
+func (b *brunoImport) body(where string, r *Request, mode string, f bruFile) {
+	switch mode {
+	case "", "none":
+	case "json", "text", "xml", "sparql":
+		r.Body = Body{Type: "raw", Value: b.vars(where, f.Text("body:"+mode))}
+		setDefault(r.Headers, "Content-Type", brunoContentTypes[mode])
+	case "formUrlEncoded":
+		data := []string{}
+		for _, kv := range f.Dict("body:form-urlencoded") {
+			if kv.Disabled {
+				b.warn(where, "disabled form field '%v' not imported", kv.Key)
+				continue
+			}
+			data = append(data, escapeVars(b.vars(where, kv.Key))+"="+escapeVars(b.vars(where, kv.Value)))
+		}
+		r.Body = Body{Type: "raw", Value: strings.Join(data, "&")}
+		setDefault(r.Headers, "Content-Type", "application/x-www-form-urlencoded")
+	case "multipartForm":
+		parts := []MultiPartPart{}
+		for _, kv := range f.Dict("body:multipart-form") {
+			if kv.Disabled {
+				b.warn(where, "disabled form field '%v' not imported", kv.Key)
+				continue
+			}
+			if bruContentType.MatchString(kv.Value) {
+				b.warn(where, "content type of form field '%v' not imported", kv.Key)
+			}
+			part := MultiPartPart{Type: "raw", Name: b.vars(where, kv.Key), Value: b.vars(where, strings.TrimSpace(bruContentType.ReplaceAllString(kv.Value, "")))}
+			if m := bruFileValue.FindStringSubmatch(kv.Value); m != nil {
+				files := strings.Split(m[1], "|")
+				if len(files) > 1 {
+					b.warn(where, "form field '%v' has several files; only the first is imported", kv.Key)
+				}
+				part.Type, part.Value = "file", b.path(files[0])
+			}
+			parts = append(parts, part)
+		}
+		buf, err := yaml.Marshal(parts)
+		if err != nil {
+			b.warn(where, "form not imported: %v", err)
+			return
+		}
+		r.Body = Body{Type: "multipart", Value: string(buf)}
+	case "file":
+		for _, kv := range f.Dict("body:file") {
+			m := bruFileValue.FindStringSubmatch(kv.Value)
+			if kv.Disabled || m == nil {
+				continue
+			}
+			r.Body = Body{Type: "file", Value: b.path(m[1])}
+			if ct := bruContentType.FindStringSubmatch(kv.Value); ct != nil && ct[1] != "" {
+				setDefault(r.Headers, "Content-Type", ct[1])
+			}
+		}
+	case "graphql":
+		// Sent as the JSON Bruno would send.
+		variables := f.Text("body:graphql:vars")
+		if variables == "" {
+			variables = "{}"
+		}
+		if !json.Valid([]byte(variables)) {
+			b.warn(where, "graphql variables aren't valid JSON; they weren't imported")
+			variables = "{}"
+		}
+		query := quoteJSON(f.Text("body:graphql"))
+		r.Body = Body{Type: "raw", Value: b.vars(where, fmt.Sprintf(`{"query":%s,"variables":%s}`, query, variables))}
+		setDefault(r.Headers, "Content-Type", "application/json")
+	default:
+		b.warn(where, "%v body not imported", mode)
+	}
+}
+
+// path makes the paths of files relative to the collection absolute.
+func (b *brunoImport) path(p string) string {
+	if filepath.IsAbs(p) {
+		return p
+	}
+	abs, err := filepath.Abs(filepath.Join(b.root, p))
+	if err != nil {
+		return p
+	}
+	return abs
+}
+
+// vars looks up the variables of Bruno ({{host}}) in the environment.
+// Process environment variables and dynamic variables aren't
+// supported.
+func (b *brunoImport) vars(where, s string) string {
+	return postmanVariable.ReplaceAllStringFunc(s, func(m string) string {
+		name := postmanVariable.FindStringSubmatch(m)[1]
+		if strings.HasPrefix(name, "$") || strings.HasPrefix(name, "process.env.") {
+			b.warn(where, "variable '%v' not supported", name)
+			return m
+		}
+		return "{{environment." + name + "}}"
+	})
+}
+
+// environments converts the .bru files in the environments folder.
+// Secrets aren't saved in them, so they are imported empty.
+func (b *brunoImport) environments(dir string) error {
+	infos, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	for _, info := range infos {
+		if info.IsDir() || filepath.Ext(info.Name()) != ".bru" {
+			continue
+		}
+		f, err := b.read(filepath.Join(dir, info.Name()))
+		if err != nil {
+			return err
+		}
+		where := "environments/" + info.Name()
+		env := Environment{}
+		for _, kv := range f.Dict("vars") {
+			if kv.Disabled {
+				b.warn(where, "disabled variable '%v' not imported", kv.Key)
+				continue
+			}
+			env[kv.Key] = b.vars(where, kv.Value)
+		}
+		for _, line := range f["vars:secret"] {
+			name := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(line), ","))
+			if name == "" || strings.HasPrefix(name, "~") {
+				continue
+			}
+			env[name] = ""
+			b.warn(where, "secret '%v' imported without its value", name)
+		}
+		b.Environments[cleanName(strings.TrimSuffix(info.Name(), ".bru"))] = env
+	}
+	return nil
+}
+
+func importbruno(c *cli.Context) error {
+	if c.Args().Len() != 1 {
+		return cli.Exit(color.Red.Sprintf("import bruno expects a collection folder"), -1)
+	}
+	b, err := ParseBruno(c.Args().First())
+	if err != nil {
+		return cli.Exit(color.Red.Sprintf("%v", err), -1)
+	}
+	return writeImport(c, b.Folders, b.Requests, b.Environments, b.Warnings)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseBrunoFolderVariables(t *testing.T) {
+	tests := []struct {
+		name   string
+		folder string
+		want   []string
+	}{
+		{"same value", "vars:pre-request {\n  host: a.test\n}\n", nil},
+		{"new variable", "vars:pre-request {\n  token: x\n}\n", nil},
+		{"different value", "vars:pre-request {\n  host: b.test\n}\n", []string{"users: variable 'host' is 'a.test' elsewhere; 'b.test' is used everywhere"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			files := map[string]string{
+				"bruno.json":       `{"version": "1", "name": "Shop", "type": "collection"}`,
+				"collection.bru":   "vars:pre-request {\n  host: a.test\n}\n",
+				"users/folder.bru": tt.folder,
+			}
+			for name, body := range files {
+				path := filepath.Join(root, filepath.FromSlash(name))
+				if err := os.MkdirAll(filepath.Dir(path), 0770); err != nil {
+					t.Fatal(err)
+				}
+				if err := ioutil.WriteFile(path, []byte(body), 0660); err != nil {
+					t.Fatal(err)
+				}
+			}
+			b, err := ParseBruno(root)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := []string{}
+			for _, w := range b.Warnings {
+				if strings.Contains(w, "variable '") {
+					got = append(got, w)
+				}
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
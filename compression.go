@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// DefaultAcceptEncoding is sent when a request doesn't specify its
+// own Accept-Encoding. It's every encoding we can decode so the
+// response shows what the server (or CDN) actually picks.
+const DefaultAcceptEncoding = "gzip, deflate, br, zstd"
+
+// decodeBody returns a reader that decodes the given
+// Content-Encoding. When multiple encodings were applied, they are
+// decoded in the reverse order.
+func decodeBody(r io.Reader, encoding string) (io.ReadCloser, error) {
+	rc := ioutil.NopCloser(r)
+	encodings := strings.Split(encoding, ",")
+	for x := len(encodings) - 1; x >= 0; x-- {
+		var err error
+		switch e := strings.ToLower(strings.TrimSpace(encodings[x])); e {
+		case "", "identity":
+		case "gzip", "x-gzip":
+			rc, err = gzip.NewReader(rc)
+		case "deflate":
+			rc, err = newDeflateReader(rc)
+		case "br":
+			rc = ioutil.NopCloser(brotli.NewReader(rc))
+		case "zstd":
+			var d *zstd.Decoder
+			if d, err = zstd.NewReader(rc); err == nil {
+				rc = d.IOReadCloser()
+			}
+		default:
+			err = fmt.Errorf("unsupported content encoding '%v'", e)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return rc, nil
+}
+
+// newDeflateReader handles both zlib wrapped deflate, which is what
+// the spec says, and raw deflate, which some servers send anyway.
+func newDeflateReader(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	header, err := br.Peek(2)
+	if err == nil && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(br)
+	}
+	return flate.NewReader(br), nil
+}
+
+// savedBody is what --body saves: the filtered body, the body as it
+// was received (compressed) or the decoded body.
+func savedBody(filtered, compressed bool, raw, decoded, display []byte) []byte {
+	switch {
+	case filtered:
+		return display
+	case compressed:
+		return raw
+	}
+	return decoded
+}
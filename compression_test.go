@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestDecodeBody(t *testing.T) {
+	body := []byte(`{"hello": "world"}`)
+	encode := func(w func(io.Writer) io.WriteCloser) func([]byte) []byte {
+		return func(b []byte) []byte {
+			buf := &bytes.Buffer{}
+			e := w(buf)
+			e.Write(b)
+			e.Close()
+			return buf.Bytes()
+		}
+	}
+	gz := encode(func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) })
+	zl := encode(func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) })
+	raw := encode(func(w io.Writer) io.WriteCloser { f, _ := flate.NewWriter(w, flate.DefaultCompression); return f })
+	br := encode(func(w io.Writer) io.WriteCloser { return brotli.NewWriter(w) })
+	zs := encode(func(w io.Writer) io.WriteCloser { z, _ := zstd.NewWriter(w); return z })
+
+	tests := []struct {
+		name     string
+		encoding string
+		body     []byte
+		err      bool
+	}{
+		{"none", "", body, false},
+		{"identity", "identity", body, false},
+		{"gzip", "gzip", gz(body), false},
+		{"x-gzip", "X-GZIP", gz(body), false},
+		{"deflate", "deflate", zl(body), false},
+		{"raw deflate", "deflate", raw(body), false},
+		{"brotli", "br", br(body), false},
+		{"zstd", "zstd", zs(body), false},
+		{"stacked", "deflate, gzip", gz(zl(body)), false},
+		{"stacked order", "br,zstd", zs(br(body)), false},
+		{"unsupported", "compress", body, true},
+		{"invalid gzip", "gzip", body, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := decodeBody(bytes.NewReader(tt.body), tt.encoding)
+			if err == nil {
+				var got []byte
+				got, err = ioutil.ReadAll(r)
+				r.Close()
+				if err == nil && !bytes.Equal(got, body) {
+					t.Errorf("got %q, want %q", got, body)
+				}
+			}
+			if (err != nil) != tt.err {
+				t.Errorf("got error %v, want error %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestSavedBody(t *testing.T) {
+	raw, decoded, display := []byte("raw"), []byte("decoded"), []byte("display")
+	tests := []struct {
+		name       string
+		filtered   bool
+		compressed bool
+		want       []byte
+	}{
+		{"decoded", false, false, decoded},
+		{"compressed", false, true, raw},
+		{"filtered", true, false, display},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := savedBody(tt.filtered, tt.compressed, raw, decoded, display); !bytes.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -20,9 +21,17 @@ type Config struct {
 	Requests     map[string]Request     `yaml:"requests,omitempty"`
 	Responses    map[string]Response    `yaml:"responses,omitempty"`
 	Preferences  map[string]string      `yaml:"preferences,omitempty"`
+
+	// EnvironmentResponses are the saved responses of the other
+	// environments. They are only used by explicit references (e.g.
+	// {{responses@prod.name.id}}).
+	EnvironmentResponses map[string]map[string]Response `yaml:"-"`
+
+	// openAPI is the document in the preferences once it's loaded.
+	openAPI *OpenAPI
 }
 
-func NewConfig(orgPath string) (*Config, error) {
+func NewConfig(orgPath, state string) (*Config, error) {
 	c := &Config{
 		Environments: make(map[string]Environment),
 		Requests:     make(map[string]Request),
@@ -34,7 +43,23 @@ func NewConfig(orgPath string) (*Config, error) {
 			return err
 		}
 
-		if !info.IsDir() && (strings.HasSuffix(path, ".yaml") || strings.HasSuffix(path, ".yml")) {
+		// The saved responses and history aren't configuration.
+		if info.IsDir() && samePath(path, state) {
+			return filepath.SkipDir
+		}
+
+		if info.IsDir() {
+			return nil
+		}
+
+		// The prefix should exclude the original path name and have
+		// no trailing or leading slashes.
+		prefix := strings.TrimPrefix(path, orgPath)
+		prefix = filepath.Dir(prefix)
+		prefix = strings.Trim(prefix, "/.")
+
+		switch filepath.Ext(path) {
+		case ".yaml", ".yml":
 			nc := &Config{}
 			buf, err := ioutil.ReadFile(path)
 			if err != nil {
@@ -44,19 +69,33 @@ func NewConfig(orgPath string) (*Config, error) {
 			if err != nil {
 				return err
 			}
-
-			// The path should exclude the original path name and have
-			// no trailing or leading slashes.
-			path = strings.TrimPrefix(path, orgPath)
-			path = filepath.Dir(path)
-			path = strings.Trim(path, "/.")
-			c.Merge(nc, path)
+			nc.setDir(filepath.Dir(path))
+			c.Merge(nc, prefix)
+		case ".http", ".rest":
+			buf, err := ioutil.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			nc, err := ParseHTTPFile(buf, filepath.Dir(path), prefix)
+			if err != nil {
+				return fmt.Errorf("parsing %v: %v", path, err)
+			}
+			nc.setDir(filepath.Dir(path))
+			c.Merge(nc, prefix)
 		}
 		return nil
 	})
 	return c, err
 }
 
+// setDir records the folder of the file the requests were read from.
+func (c *Config) setDir(dir string) {
+	for k, r := range c.Requests {
+		r.dir = dir
+		c.Requests[k] = r
+	}
+}
+
 func (c *Config) Merge(nc *Config, path string) {
 	// Only include the trailing slash if we have a value.
 	prefix := path
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestNewConfigSkipsState(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"c.yaml":                        "requests:\n  a:\n    url: http://example.com/a\n",
+		".shared/b.yaml":                "requests:\n  b:\n    url: http://example.com/b\n",
+		".aa/state/dev/c-response.yaml": "responses:\n  c:\n    status_code: 200\n",
+		"out/dev/d-response.yaml":       "responses:\n  d:\n    status_code: 200\n",
+	}
+	for name, body := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0770); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(body), 0660); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	tests := []struct {
+		name      string
+		config    string
+		state     string
+		responses string
+	}{
+		{"default", dir, filepath.Join(dir, ".aa", "state"), "d"},
+		{"inside", dir, filepath.Join(dir, "out"), "c"},
+		{"relative", dir, "out", "c"},
+		{"relative config", ".", filepath.Join(dir, "out"), "c"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := NewConfig(tt.config, tt.state)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var requests, responses []string
+			for k := range cfg.Requests {
+				requests = append(requests, k)
+			}
+			for k := range cfg.Responses {
+				responses = append(responses, k)
+			}
+			sort.Strings(requests)
+			if got := strings.Join(requests, ","); got != "a,shared/b" {
+				t.Errorf("got requests %v, want a,shared/b", got)
+			}
+			if got := strings.Join(responses, ","); got != tt.responses {
+				t.Errorf("got responses %v, want %v", got, tt.responses)
+			}
+		})
+	}
+}
@@ -0,0 +1,409 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// splitShellWords splits a command line the way a POSIX shell would.
+// It understands single, double and $'...' quotes, backslash escapes
+// and line continuations. Windows cmd's ^ continuations (from copying
+// as cURL on Windows) are also removed.
+func splitShellWords(s string) ([]string, error) {
+	s = strings.ReplaceAll(s, "^\r\n", "")
+	s = strings.ReplaceAll(s, "^\n", "")
+
+	words := []string{}
+	var word strings.Builder
+	inWord := false
+	r := []rune(s)
+	for x := 0; x < len(r); x++ {
+		c := r[x]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+
+		case c == '\\':
+			if x+1 < len(r) {
+				x++
+				if r[x] == '\n' {
+					// A line continuation.
+					continue
+				}
+				word.WriteRune(r[x])
+			}
+			inWord = true
+
+		case c == '\'':
+			inWord = true
+			end := indexRune(r, '\'', x+1)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated single quote")
+			}
+			word.WriteString(string(r[x+1 : end]))
+			x = end
+
+		case c == '$' && x+1 < len(r) && r[x+1] == '\'':
+			inWord = true
+			x += 2
+			for ; x < len(r) && r[x] != '\''; x++ {
+				if r[x] != '\\' || x+1 >= len(r) {
+					word.WriteRune(r[x])
+					continue
+				}
+				x++
+				switch r[x] {
+				case 'n':
+					word.WriteRune('\n')
+				case 't':
+					word.WriteRune('\t')
+				case 'r':
+					word.WriteRune('\r')
+				case 'x':
+					// \xHH
+					if x+2 < len(r) {
+						var b byte
+						if _, err := fmt.Sscanf(string(r[x+1:x+3]), "%02x", &b); err == nil {
+							word.WriteByte(b)
+							x += 2
+							continue
+						}
+					}
+					word.WriteString(`\x`)
+				case 'u':
+					// \uHHHH
+					if x+4 < len(r) {
+						var u rune
+						if _, err := fmt.Sscanf(string(r[x+1:x+5]), "%04x", &u); err == nil {
+							word.WriteRune(u)
+							x += 4
+							continue
+						}
+					}
+					word.WriteString(`\u`)
+				default:
+					word.WriteRune(r[x])
+				}
+			}
+			if x >= len(r) {
+				return nil, fmt.Errorf("unterminated $' quote")
+			}
+
+		case c == '"':
+			inWord = true
+			x++
+			for ; x < len(r) && r[x] != '"'; x++ {
+				if r[x] == '\\' && x+1 < len(r) && strings.ContainsRune("$`\"\\\n", r[x+1]) {
+					x++
+					if r[x] == '\n' {
+						continue
+					}
+				}
+				word.WriteRune(r[x])
+			}
+			if x >= len(r) {
+				return nil, fmt.Errorf("unterminated double quote")
+			}
+
+		default:
+			inWord = true
+			word.WriteRune(c)
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+func indexRune(r []rune, c rune, start int) int {
+	for x := start; x < len(r); x++ {
+		if r[x] == c {
+			return x
+		}
+	}
+	return -1
+}
+
+// curlValueOptions are the curl options that take a value. The ones we
+// don't use are skipped along with their value.
+var curlValueOptions = map[string]bool{
+	"-X": true, "--request": true,
+	"-H": true, "--header": true,
+	"-d": true, "--data": true, "--data-ascii": true, "--data-raw": true, "--data-binary": true, "--data-urlencode": true, "--json": true,
+	"-F": true, "--form": true, "--form-string": true,
+	"-u": true, "--user": true,
+	"-A": true, "--user-agent": true,
+	"-e": true, "--referer": true,
+	"-b": true, "--cookie": true,
+	"-E": true, "--cert": true, "--key": true, "--cacert": true,
+	"--url": true, "--unix-socket": true, "--oauth2-bearer": true,
+	"-o": true, "--output": true, "-m": true, "--max-time": true, "--connect-timeout": true,
+	"-w": true, "--write-out": true, "-x": true, "--proxy": true, "--resolve": true,
+	"-c": true, "--cookie-jar": true, "--retry": true, "-T": true, "--upload-file": true,
+	"-r": true, "--range": true, "-y": true, "--speed-time": true, "-Y": true, "--speed-limit": true,
+	"--cert-type": true, "--key-type": true, "--pass": true, "--capath": true, "--limit-rate": true,
+	"--max-redirs": true, "--interface": true, "--dns-servers": true, "--retry-delay": true, "--retry-max-time": true,
+}
+
+// ParseCurl converts a curl command line into a request. Options that
+// don't change the request (like -s or -o) are ignored. Options we
+// can't represent are returned as warnings.
+func ParseCurl(args []string) (Request, []string, error) {
+	r := Request{
+		Headers:        map[string]string{},
+		Authentication: map[string]string{},
+		Query:          map[string]string{},
+	}
+	warnings := []string{}
+	if len(args) > 0 && (args[0] == "curl" || strings.HasSuffix(args[0], "/curl") || strings.HasSuffix(args[0], "curl.exe")) {
+		args = args[1:]
+	}
+
+	// Expand short option clusters (-sSL) and attached values
+	// (-XPOST) so every option is its own word.
+	words := []string{}
+	for x := 0; x < len(args); x++ {
+		a := args[x]
+		if len(a) > 2 && a[0] == '-' && a[1] != '-' {
+			for y := 1; y < len(a); y++ {
+				opt := "-" + string(a[y])
+				words = append(words, opt)
+				if curlValueOptions[opt] && y+1 < len(a) {
+					words = append(words, a[y+1:])
+					break
+				}
+			}
+			continue
+		}
+		words = append(words, a)
+	}
+
+	var rawURL, socket string
+	var data []string
+	var dataFile string
+	var form []MultiPartPart
+	get := false
+	for x := 0; x < len(words); x++ {
+		opt := words[x]
+		var val string
+		if strings.HasPrefix(opt, "-") && curlValueOptions[opt] {
+			if x+1 >= len(words) {
+				return r, warnings, fmt.Errorf("missing value for %v", opt)
+			}
+			x++
+			val = words[x]
+		}
+
+		switch opt {
+		case "-X", "--request":
+			r.Method = strings.ToUpper(val)
+		case "-H", "--header":
+			kv := strings.SplitN(val, ":", 2)
+			if len(kv) != 2 {
+				warnings = append(warnings, fmt.Sprintf("ignoring header '%v'", val))
+				continue
+			}
+			k, v := strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+			if strings.EqualFold(k, "Authorization") && strings.HasPrefix(strings.ToLower(v), "bearer ") {
+				r.Authentication["type"] = "bearer"
+				r.Authentication["token"] = strings.TrimSpace(v[len("bearer "):])
+				continue
+			}
+			r.Headers[k] = v
+		case "-A", "--user-agent":
+			r.Headers["User-Agent"] = val
+		case "-e", "--referer":
+			r.Headers["Referer"] = val
+		case "-b", "--cookie":
+			if !strings.Contains(val, "=") {
+				warnings = append(warnings, fmt.Sprintf("ignoring cookie file '%v'", val))
+				continue
+			}
+			r.Headers["Cookie"] = val
+		case "--oauth2-bearer":
+			r.Authentication["type"] = "bearer"
+			r.Authentication["token"] = val
+		case "-u", "--user":
+			kv := strings.SplitN(val, ":", 2)
+			r.Authentication["type"] = "basic"
+			r.Authentication["username"] = kv[0]
+			if len(kv) == 2 {
+				r.Authentication["password"] = kv[1]
+			}
+		case "-d", "--data", "--data-ascii", "--data-binary":
+			if strings.HasPrefix(val, "@") {
+				dataFile = val[1:]
+				continue
+			}
+			data = append(data, val)
+		case "--data-raw":
+			data = append(data, val)
+		case "--data-urlencode":
+			data = append(data, curlURLEncode(val))
+		case "--json":
+			if strings.HasPrefix(val, "@") {
+				dataFile = val[1:]
+			} else {
+				data = append(data, val)
+			}
+			setDefault(r.Headers, "Content-Type", "application/json")
+			setDefault(r.Headers, "Accept", "application/json")
+		case "-F", "--form", "--form-string":
+			kv := strings.SplitN(val, "=", 2)
+			if len(kv) != 2 {
+				return r, warnings, fmt.Errorf("invalid form field '%v'", val)
+			}
+			part := MultiPartPart{Type: "raw", Name: kv[0], Value: kv[1]}
+			if opt != "--form-string" && strings.HasPrefix(kv[1], "@") {
+				part.Type = "file"
+				part.Value = strings.SplitN(kv[1][1:], ";", 2)[0]
+			}
+			form = append(form, part)
+		case "-G", "--get":
+			get = true
+		case "-k", "--insecure":
+			r.TLS.Insecure = true
+		case "-E", "--cert":
+			// Passwords (cert:password) aren't supported.
+			r.TLS.Cert = strings.SplitN(val, ":", 2)[0]
+		case "--key":
+			r.TLS.Key = val
+		case "--cacert":
+			r.TLS.CA = val
+		case "--compressed":
+			r.AcceptEncoding = "gzip, deflate, br, zstd"
+		case "--http1.1", "--http1.0":
+			r.Protocol = ProtocolHTTP1
+		case "--http2":
+			r.Protocol = ProtocolH2
+		case "--http2-prior-knowledge":
+			r.Protocol = ProtocolH2C
+		case "--unix-socket":
+			socket = val
+		case "--url":
+			rawURL = val
+		case "-I", "--head":
+			r.Method = "HEAD"
+		case "-x", "--proxy":
+			warnings = append(warnings, "proxies are configured in the environment (proxy.http, proxy.https)")
+		case "--resolve":
+			warnings = append(warnings, "resolve is configured in the environment or preferences")
+		default:
+			if strings.HasPrefix(opt, "-") {
+				if !curlValueOptions[opt] && !curlIgnoredOptions[opt] {
+					warnings = append(warnings, fmt.Sprintf("ignoring unknown option %v", opt))
+				}
+				continue
+			}
+			if rawURL != "" {
+				return r, warnings, fmt.Errorf("more than one url ('%v' and '%v')", rawURL, opt)
+			}
+			rawURL = opt
+		}
+	}
+
+	if rawURL == "" {
+		return r, warnings, fmt.Errorf("no url found")
+	}
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "http://" + rawURL
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return r, warnings, fmt.Errorf("parsing url: %v", err)
+	}
+
+	// With -G the data is added to the query.
+	if get && len(data) > 0 {
+		if u.RawQuery != "" {
+			u.RawQuery += "&"
+		}
+		u.RawQuery += strings.Join(data, "&")
+		data = nil
+	}
+
+	// Repeated query values can't be represented, so the last wins.
+	for k, v := range u.Query() {
+		r.Query[k] = v[len(v)-1]
+		if len(v) > 1 {
+			warnings = append(warnings, fmt.Sprintf("repeated query parameter '%v'; only the last value is kept", k))
+		}
+	}
+	u.RawQuery = ""
+	if socket != "" {
+		r.URL = "unix://" + socket + ":" + u.EscapedPath()
+	} else {
+		r.URL = u.String()
+	}
+
+	switch {
+	case len(form) > 0:
+		buf, err := yaml.Marshal(form)
+		if err != nil {
+			return r, warnings, err
+		}
+		r.Body = Body{Type: "multipart", Value: string(buf)}
+	case dataFile != "":
+		if len(data) > 0 {
+			warnings = append(warnings, "only the data from the file is used")
+		}
+		r.Body = Body{Type: "file", Value: dataFile}
+	case len(data) > 0:
+		r.Body = Body{Type: "raw", Value: strings.Join(data, "&")}
+	}
+	if r.Body.Type == "raw" || r.Body.Type == "file" {
+		setDefault(r.Headers, "Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	if r.Method == "" {
+		r.Method = "GET"
+		if r.Body.Type != "" {
+			r.Method = "POST"
+		}
+	}
+	return r, warnings, nil
+}
+
+// curlIgnoredOptions are the curl options without values that don't
+// change the request.
+var curlIgnoredOptions = map[string]bool{
+	"-s": true, "--silent": true, "-S": true, "--show-error": true, "-L": true, "--location": true,
+	"-v": true, "--verbose": true, "-i": true, "--include": true, "-f": true, "--fail": true,
+	"-#": true, "--progress-bar": true, "-N": true, "--no-buffer": true, "-O": true, "--remote-name": true,
+	"-g": true, "--globoff": true, "--fail-with-body": true, "--no-progress-meter": true,
+	"--path-as-is": true, "--tr-encoding": true, "-4": true, "-6": true,
+}
+
+// curlURLEncode encodes a --data-urlencode value the way curl does.
+// Spaces are %20 rather than +.
+func curlURLEncode(v string) string {
+	escape := func(s string) string {
+		return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+	}
+	switch {
+	case strings.HasPrefix(v, "="):
+		return escape(v[1:])
+	case strings.Contains(v, "="):
+		kv := strings.SplitN(v, "=", 2)
+		return kv[0] + "=" + escape(kv[1])
+	}
+	return escape(v)
+}
+
+// setDefault sets the header if it hasn't been set yet.
+func setDefault(h map[string]string, k, v string) {
+	for hk := range h {
+		if strings.EqualFold(hk, k) {
+			return
+		}
+	}
+	h[k] = v
+}
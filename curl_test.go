@@ -0,0 +1,189 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitShellWords(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want []string
+		err  string
+	}{
+		{"words", "curl  -s\thttp://a", []string{"curl", "-s", "http://a"}, ""},
+		{"single quotes", `-H 'X-A: "b" \n'`, []string{"-H", `X-A: "b" \n`}, ""},
+		{"double quotes", `-d "a \"b\" \$c \\ \n"`, []string{"-d", `a "b" $c \ \n`}, ""},
+		{"dollar quotes", `$'a\nb\t\x41é\'c'`, []string{"a\nb\tAé'c"}, ""},
+		{"escapes", `a\ b \'c\"`, []string{"a b", `'c"`}, ""},
+		{"adjacent quotes", `a'b'"c"$'d'`, []string{"abcd"}, ""},
+		{"empty quotes", `'' ""`, []string{"", ""}, ""},
+		{"continuations", "curl \\\n  -s \\\n  http://a", []string{"curl", "-s", "http://a"}, ""},
+		{"quoted continuation", "\"a\\\nb\"", []string{"ab"}, ""},
+		{"windows continuations", "curl ^\r\n  -s ^\n  http://a", []string{"curl", "-s", "http://a"}, ""},
+		{"unterminated single quote", `'a`, nil, "unterminated single quote"},
+		{"unterminated double quote", `"a`, nil, "unterminated double quote"},
+		{"unterminated dollar quote", `$'a`, nil, "unterminated $' quote"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := splitShellWords(tt.in)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("got error %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCurl(t *testing.T) {
+	tests := []struct {
+		name     string
+		cmd      string
+		want     Request
+		warnings []string
+		err      string
+	}{
+		{
+			name: "get",
+			cmd:  "curl example.com/a",
+			want: Request{Method: "GET", URL: "http://example.com/a"},
+		},
+		{
+			name: "headers",
+			cmd:  `curl -sSL -H 'Accept: application/json' -H "Authorization: Bearer abc" -A agent -H bad https://example.com`,
+			want: Request{
+				Method:         "GET",
+				URL:            "https://example.com",
+				Headers:        map[string]string{"Accept": "application/json", "User-Agent": "agent"},
+				Authentication: map[string]string{"type": "bearer", "token": "abc"},
+			},
+			warnings: []string{"ignoring header 'bad'"},
+		},
+		{
+			name: "user",
+			cmd:  "curl -u user:pa:ss https://example.com",
+			want: Request{
+				Method:         "GET",
+				URL:            "https://example.com",
+				Authentication: map[string]string{"type": "basic", "username": "user", "password": "pa:ss"},
+			},
+		},
+		{
+			name: "user without password",
+			cmd:  "curl --user user https://example.com",
+			want: Request{
+				Method:         "GET",
+				URL:            "https://example.com",
+				Authentication: map[string]string{"type": "basic", "username": "user"},
+			},
+		},
+		{
+			name: "data",
+			cmd:  `curl -XPUT https://example.com -d a=1 --data-raw '@b=2'`,
+			want: Request{
+				Method:  "PUT",
+				URL:     "https://example.com",
+				Headers: map[string]string{"Content-Type": "application/x-www-form-urlencoded"},
+				Body:    Body{Type: "raw", Value: "a=1&@b=2"},
+			},
+		},
+		{
+			name: "data urlencode",
+			cmd:  `curl https://example.com --data-urlencode 'q=a b&c' --data-urlencode '=x/y' --data-urlencode 'é~'`,
+			want: Request{
+				Method:  "POST",
+				URL:     "https://example.com",
+				Headers: map[string]string{"Content-Type": "application/x-www-form-urlencoded"},
+				Body:    Body{Type: "raw", Value: "q=a%20b%26c&x%2Fy&%C3%A9~"},
+			},
+		},
+		{
+			name: "get data",
+			cmd:  `curl -G https://example.com?a=1 --data-urlencode 'b=x y'`,
+			want: Request{
+				Method: "GET",
+				URL:    "https://example.com",
+				Query:  map[string]string{"a": "1", "b": "x y"},
+			},
+		},
+		{
+			name: "data file",
+			cmd:  `curl https://example.com -d @body.json -d a=1`,
+			want: Request{
+				Method:  "POST",
+				URL:     "https://example.com",
+				Headers: map[string]string{"Content-Type": "application/x-www-form-urlencoded"},
+				Body:    Body{Type: "file", Value: "body.json"},
+			},
+			warnings: []string{"only the data from the file is used"},
+		},
+		{
+			name: "repeated query",
+			cmd:  `curl 'https://example.com/a?id=1&id=2&b=3'`,
+			want: Request{
+				Method: "GET",
+				URL:    "https://example.com/a",
+				Query:  map[string]string{"id": "2", "b": "3"},
+			},
+			warnings: []string{"repeated query parameter 'id'; only the last value is kept"},
+		},
+		{
+			name: "unsupported",
+			cmd:  `curl -x http://proxy:3128 --resolve a:443:127.0.0.1 --frobnicate -o out https://example.com`,
+			want: Request{Method: "GET", URL: "https://example.com"},
+			warnings: []string{
+				"proxies are configured in the environment (proxy.http, proxy.https)",
+				"resolve is configured in the environment or preferences",
+				"ignoring unknown option --frobnicate",
+			},
+		},
+		{
+			name: "unix socket",
+			cmd:  `curl --unix-socket /var/run/docker.sock http://localhost/v1/info`,
+			want: Request{Method: "GET", URL: "unix:///var/run/docker.sock:/v1/info"},
+		},
+		{name: "missing value", cmd: "curl https://example.com -H", err: "missing value for -H"},
+		{name: "no url", cmd: "curl -s", err: "no url found"},
+		{name: "two urls", cmd: "curl a b", err: "more than one url ('a' and 'b')"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args, err := splitShellWords(tt.cmd)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, warnings, err := ParseCurl(args)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("got error %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, m := range []*map[string]string{&tt.want.Headers, &tt.want.Authentication, &tt.want.Query} {
+				if *m == nil {
+					*m = map[string]string{}
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+			if strings.Join(warnings, "\n") != strings.Join(tt.warnings, "\n") {
+				t.Errorf("got warnings %q, want %q", warnings, tt.warnings)
+			}
+		})
+	}
+}
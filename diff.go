@@ -0,0 +1,238 @@
+package main
+
+import (
+	"fmt"
+	"mime"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gookit/color"
+)
+
+// MaxDiffLines is the most lines of a non-JSON body we'll diff line
+// by line.
+const MaxDiffLines = 2000
+
+// The kinds of differences.
+const (
+	DiffAdded   = "+"
+	DiffRemoved = "-"
+	DiffChanged = "~"
+)
+
+// Difference is a single change between two values. Old is empty
+// for added values and New is empty for removed ones.
+type Difference struct {
+	Kind string
+	Path string
+	Old  string
+	New  string
+}
+
+func (d Difference) String() string {
+	switch d.Kind {
+	case DiffAdded:
+		return fmt.Sprintf("%v %v: %v", d.Kind, d.Path, d.New)
+	case DiffRemoved:
+		return fmt.Sprintf("%v %v: %v", d.Kind, d.Path, d.Old)
+	}
+	return fmt.Sprintf("%v %v: %v -> %v", d.Kind, d.Path, d.Old, d.New)
+}
+
+// Print writes the difference colored by its kind.
+func (d Difference) Print() {
+	switch d.Kind {
+	case DiffAdded:
+		color.Green.Println(d.String())
+	case DiffRemoved:
+		color.Red.Println(d.String())
+	default:
+		color.Yellow.Println(d.String())
+	}
+}
+
+// diffMaps finds the differences between two string maps. The keys
+// of the map are used as the path. Keys in ignore are skipped.
+func diffMaps(a, b map[string]string, ignore []string) []Difference {
+	skip := map[string]bool{}
+	for _, k := range ignore {
+		skip[strings.ToLower(k)] = true
+	}
+
+	keys := map[string]bool{}
+	for k := range a {
+		keys[k] = true
+	}
+	for k := range b {
+		keys[k] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		if !skip[strings.ToLower(k)] {
+			sorted = append(sorted, k)
+		}
+	}
+	sort.Strings(sorted)
+
+	diffs := []Difference{}
+	for _, k := range sorted {
+		av, aok := a[k]
+		bv, bok := b[k]
+		switch {
+		case !aok:
+			diffs = append(diffs, Difference{Kind: DiffAdded, Path: k, New: bv})
+		case !bok:
+			diffs = append(diffs, Difference{Kind: DiffRemoved, Path: k, Old: av})
+		case av != bv:
+			diffs = append(diffs, Difference{Kind: DiffChanged, Path: k, Old: av, New: bv})
+		}
+	}
+	return diffs
+}
+
+// diffJSON structurally compares two values from decodeJSON. Paths
+// are written in the same syntax as filters so they can be used to
+// look at the values (e.g. $.items[2].id).
+func diffJSON(path string, a, b interface{}) []Difference {
+	switch av := a.(type) {
+	case *jsonObject:
+		bv, ok := b.(*jsonObject)
+		if !ok {
+			break
+		}
+		diffs := []Difference{}
+		for _, k := range av.keys {
+			p := path + pathKey(k)
+			if v, ok := bv.Get(k); ok {
+				diffs = append(diffs, diffJSON(p, av.values[k], v)...)
+			} else {
+				diffs = append(diffs, Difference{Kind: DiffRemoved, Path: p, Old: jsonString(av.values[k])})
+			}
+		}
+		for _, k := range bv.keys {
+			if _, ok := av.Get(k); !ok {
+				diffs = append(diffs, Difference{Kind: DiffAdded, Path: path + pathKey(k), New: jsonString(bv.values[k])})
+			}
+		}
+		return diffs
+
+	case []interface{}:
+		bv, ok := b.([]interface{})
+		if !ok {
+			break
+		}
+		diffs := []Difference{}
+		for x := 0; x < len(av) || x < len(bv); x++ {
+			p := fmt.Sprintf("%v[%v]", path, x)
+			switch {
+			case x >= len(bv):
+				diffs = append(diffs, Difference{Kind: DiffRemoved, Path: p, Old: jsonString(av[x])})
+			case x >= len(av):
+				diffs = append(diffs, Difference{Kind: DiffAdded, Path: p, New: jsonString(bv[x])})
+			default:
+				diffs = append(diffs, diffJSON(p, av[x], bv[x])...)
+			}
+		}
+		return diffs
+	}
+
+	as, bs := jsonString(a), jsonString(b)
+	if as == bs {
+		return nil
+	}
+	return []Difference{{Kind: DiffChanged, Path: path, Old: as, New: bs}}
+}
+
+var identifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+// pathKey writes a key as part of a path.
+func pathKey(k string) string {
+	if identifier.MatchString(k) {
+		return "." + k
+	}
+	return "[" + quoteJSON(k) + "]"
+}
+
+func jsonString(v interface{}) string {
+	return string(encodeJSON(v, "", PlainPalette))
+}
+
+// diffLines compares two texts line by line. Unchanged lines are
+// prefixed with a space and the rest with the kind of change.
+func diffLines(a, b string) []string {
+	al := strings.Split(a, "\n")
+	bl := strings.Split(b, "\n")
+
+	// lcs[x][y] is the length of the longest common subsequence of
+	// al[x:] and bl[y:].
+	lcs := make([][]int, len(al)+1)
+	for x := range lcs {
+		lcs[x] = make([]int, len(bl)+1)
+	}
+	for x := len(al) - 1; x >= 0; x-- {
+		for y := len(bl) - 1; y >= 0; y-- {
+			if al[x] == bl[y] {
+				lcs[x][y] = lcs[x+1][y+1] + 1
+			} else if lcs[x+1][y] >= lcs[x][y+1] {
+				lcs[x][y] = lcs[x+1][y]
+			} else {
+				lcs[x][y] = lcs[x][y+1]
+			}
+		}
+	}
+
+	lines := []string{}
+	x, y := 0, 0
+	for x < len(al) || y < len(bl) {
+		switch {
+		case x < len(al) && y < len(bl) && al[x] == bl[y]:
+			lines = append(lines, "  "+al[x])
+			x++
+			y++
+		case x < len(al) && (y == len(bl) || lcs[x+1][y] >= lcs[x][y+1]):
+			lines = append(lines, DiffRemoved+" "+al[x])
+			x++
+		default:
+			lines = append(lines, DiffAdded+" "+bl[y])
+			y++
+		}
+	}
+	return lines
+}
+
+// diffBodies prints the differences between the bodies. JSON bodies
+// are compared structurally and text bodies line by line.
+func diffBodies(contentType, a, b string) {
+	mt, _, _ := mime.ParseMediaType(strings.Trim(contentType, "[]"))
+	av, aerr := decodeJSON([]byte(a))
+	bv, berr := decodeJSON([]byte(b))
+	if aerr == nil && berr == nil {
+		for _, d := range diffJSON("$", av, bv) {
+			d.Print()
+		}
+		return
+	}
+
+	if isBinary([]byte(a)) || isBinary([]byte(b)) {
+		color.Yellow.Printf("<binary bodies differ (%v bytes -> %v bytes)>\n", len(a), len(b))
+		return
+	}
+	// Pretty print what we can so the lines are comparable.
+	at := string(prettyBody(mt, []byte(a), "  ", PlainPalette))
+	bt := string(prettyBody(mt, []byte(b), "  ", PlainPalette))
+	if strings.Count(at, "\n") > MaxDiffLines || strings.Count(bt, "\n") > MaxDiffLines {
+		color.Yellow.Printf("<bodies differ (%v bytes -> %v bytes)>\n", len(a), len(b))
+		return
+	}
+	for _, line := range diffLines(at, bt) {
+		switch line[0:1] {
+		case DiffAdded:
+			color.Green.Println(line)
+		case DiffRemoved:
+			color.Red.Println(line)
+		default:
+			fmt.Println(line)
+		}
+	}
+}
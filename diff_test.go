@@ -0,0 +1,86 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiffMaps(t *testing.T) {
+	a := map[string]string{"A": "1", "B": "2", "Date": "x", "C": "3"}
+	b := map[string]string{"A": "1", "B": "4", "Date": "y", "D": "5"}
+	got := diffMaps(a, b, []string{"date"})
+	want := []Difference{
+		{Kind: DiffChanged, Path: "B", Old: "2", New: "4"},
+		{Kind: DiffRemoved, Path: "C", Old: "3"},
+		{Kind: DiffAdded, Path: "D", New: "5"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestDiffJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		a    string
+		b    string
+		want []string
+	}{
+		{"same", `{"a": [1, {"b": 2}]}`, `{"a":[1,{"b":2}]}`, nil},
+		{"changed", `{"a": 1, "b": "x"}`, `{"a": 2, "b": "x"}`, []string{"~ $.a: 1 -> 2"}},
+		{"added and removed", `{"a": 1, "b": 2}`, `{"b": 2, "c": {"d": true}}`, []string{
+			"- $.a: 1",
+			`+ $.c: {"d":true}`,
+		}},
+		{"nested", `{"items": [{"id": 1}, {"id": 2}]}`, `{"items": [{"id": 1}, {"id": 3}]}`, []string{"~ $.items[1].id: 2 -> 3"}},
+		{"longer array", `[1, 2]`, `[1, 2, 3]`, []string{"+ $[2]: 3"}},
+		{"shorter array", `[1, 2, 3]`, `[1]`, []string{"- $[1]: 2", "- $[2]: 3"}},
+		{"type", `{"a": [1]}`, `{"a": {"0": 1}}`, []string{`~ $.a: [1] -> {"0":1}`}},
+		{"number and string", `{"a": 1}`, `{"a": "1"}`, []string{`~ $.a: 1 -> "1"`}},
+		{"quoted keys", `{"a.b": 1, "c d": 2}`, `{"a.b": 2, "c d": 2}`, []string{`~ $["a.b"]: 1 -> 2`}},
+		{"null", `{"a": null}`, `{"a": false}`, []string{"~ $.a: null -> false"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := decodeJSON([]byte(tt.a))
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := decodeJSON([]byte(tt.b))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := []string{}
+			for _, d := range diffJSON("$", a, b) {
+				got = append(got, d.String())
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("got\n%v\nwant\n%v", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		name string
+		a    string
+		b    string
+		want []string
+	}{
+		{"same", "a\nb", "a\nb", []string{"  a", "  b"}},
+		{"changed", "a\nb\nc", "a\nx\nc", []string{"  a", "- b", "+ x", "  c"}},
+		{"added", "a\nc", "a\nb\nc\nd", []string{"  a", "+ b", "  c", "+ d"}},
+		{"removed", "a\nb\nc", "b", []string{"- a", "  b", "- c"}},
+		{"empty", "", "a", []string{"- ", "+ a"}},
+		{"moved", "a\nb\nc", "c\na\nb", []string{"+ c", "  a", "  b", "- c"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := diffLines(tt.a, tt.b); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
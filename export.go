@@ -0,0 +1,435 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gookit/color"
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// The formats requests can be exported as.
+const (
+	ExportCurl   = "curl"
+	ExportHTTPie = "httpie"
+	ExportRaw    = "raw"
+	ExportGo     = "go"
+)
+
+func requestexport(c *cli.Context, cfg *Config, env Environment) error {
+	if !c.Args().Present() {
+		return cli.Exit(color.Red.Sprintf("export expects at least one request name"), -1)
+	}
+
+	vars := flattenVars(c, cfg, env)
+	for x := 0; x < c.Args().Len(); x++ {
+		name := c.Args().Get(x)
+		req, ok := cfg.Requests[name]
+		if !ok {
+			return cli.Exit(color.Red.Sprintf("request '%v' not found", name), -1)
+		}
+		req.Interpolate(vars)
+		if req.Type != "" && req.Type != RequestTypeHTTP {
+			return cli.Exit(color.Red.Sprintf("exporting %v: only http requests can be exported", name), -1)
+		}
+
+		// Requests without a method are sent as GET.
+		if req.Method == "" {
+			req.Method = http.MethodGet
+		}
+
+		// The preference skips verification like it does for runs.
+		if cfg.Preferences["ignore-certs"] == "true" {
+			req.TLS.Insecure = true
+		}
+
+		var out []byte
+		var warnings []string
+		var err error
+		switch c.String("format") {
+		case ExportCurl:
+			out, warnings, err = exportCurl(req)
+		case ExportHTTPie:
+			out, warnings, err = exportHTTPie(req)
+		case ExportRaw:
+			out, err = exportRaw(req)
+		case ExportGo:
+			out, warnings, err = exportGo(req)
+		default:
+			err = fmt.Errorf("unsupported format '%v' (valid: %v, %v, %v, %v)",
+				c.String("format"), ExportCurl, ExportHTTPie, ExportRaw, ExportGo)
+		}
+		if err != nil {
+			return cli.Exit(color.Red.Sprintf("exporting %v: %v", name, err), -1)
+		}
+
+		for _, w := range warnings {
+			fmt.Fprint(os.Stderr, color.Yellow.Sprintf("<%v: %v>\n", name, w))
+		}
+		if c.Args().Len() > 1 {
+			fmt.Printf("# %v\n", name)
+		}
+		fmt.Printf("%s\n", bytes.TrimRight(out, "\n"))
+		if x < c.Args().Len()-1 {
+			fmt.Println()
+		}
+	}
+	return nil
+}
+
+var shellSafe = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// shellQuote quotes s for a POSIX shell.
+func shellQuote(s string) string {
+	if shellSafe.MatchString(s) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// multipartParts parses the parts of a multipart body.
+func multipartParts(b Body) ([]MultiPartPart, error) {
+	parts := []MultiPartPart{}
+	if err := yaml.Unmarshal([]byte(b.Value), &parts); err != nil {
+		return nil, fmt.Errorf("parsing multipart body: %v", err)
+	}
+	return parts, nil
+}
+
+// sortedMap returns the keys of the map in order so exports are the
+// same every time.
+func sortedMap(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func exportCurl(r Request) ([]byte, []string, error) {
+	u, socket, err := requestURL(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// curl waits for the body of a HEAD response with -X HEAD.
+	args := command{}
+	switch {
+	case strings.EqualFold(r.Method, http.MethodHead):
+		args.add("curl", "-I", shellQuote(u.String()))
+	case r.Method != "GET" || r.Body.Type != "":
+		args.add("curl", "-X", shellQuote(r.Method), shellQuote(u.String()))
+	default:
+		args.add("curl", shellQuote(u.String()))
+	}
+	if socket != "" {
+		args.add("--unix-socket", shellQuote(socket))
+	}
+	switch r.Protocol {
+	case ProtocolHTTP1:
+		args.add("--http1.1")
+	case ProtocolH2:
+		args.add("--http2")
+	case ProtocolH2C:
+		args.add("--http2-prior-knowledge")
+	}
+
+	for _, k := range sortedMap(r.Headers) {
+		args.add("-H", shellQuote(k+": "+r.Headers[k]))
+	}
+	if r.AcceptEncoding != "" {
+		args.add("-H", shellQuote("Accept-Encoding: "+r.AcceptEncoding))
+	}
+	switch strings.ToLower(r.Authentication["type"]) {
+	case "bearer":
+		args.add("-H", shellQuote("Authorization: Bearer "+r.Authentication["token"]))
+	case "basic":
+		args.add("-u", shellQuote(r.Authentication["username"]+":"+r.Authentication["password"]))
+	}
+
+	if r.TLS.Insecure {
+		args.add("-k")
+	}
+	if r.TLS.Cert != "" {
+		args.add("--cert", shellQuote(r.TLS.Cert))
+	}
+	if r.TLS.Key != "" {
+		args.add("--key", shellQuote(r.TLS.Key))
+	}
+	if r.TLS.CA != "" {
+		args.add("--cacert", shellQuote(r.TLS.CA))
+	}
+
+	switch r.Body.Type {
+	case "raw":
+		args.add("--data-raw", shellQuote(r.Body.Value))
+	case "file":
+		args.add("--data-binary", shellQuote("@"+r.Body.Value))
+	case "multipart":
+		parts, err := multipartParts(r.Body)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, p := range parts {
+			if p.Type == "file" {
+				args.add("-F", shellQuote(p.Name+"=@"+p.Value))
+			} else {
+				args.add("--form-string", shellQuote(p.Name+"="+p.Value))
+			}
+		}
+	}
+
+	return []byte(args.String()), nil, nil
+}
+
+func exportHTTPie(r Request) ([]byte, []string, error) {
+	u, socket, err := requestURL(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	warnings := []string{}
+	if socket != "" {
+		warnings = append(warnings, "httpie needs a plugin for unix sockets")
+	}
+	if r.Protocol == ProtocolH2 || r.Protocol == ProtocolH2C {
+		warnings = append(warnings, "httpie only speaks HTTP/1.1")
+	}
+
+	args := command{{"http"}}
+	if r.TLS.Insecure {
+		args.add("--verify=no")
+	} else if r.TLS.CA != "" {
+		args.add(shellQuote("--verify=" + r.TLS.CA))
+	}
+	if r.TLS.Cert != "" {
+		args.add(shellQuote("--cert=" + r.TLS.Cert))
+	}
+	if r.TLS.Key != "" {
+		args.add(shellQuote("--cert-key=" + r.TLS.Key))
+	}
+	switch strings.ToLower(r.Authentication["type"]) {
+	case "bearer":
+		args.add("-A", "bearer", "-a", shellQuote(r.Authentication["token"]))
+	case "basic":
+		args.add("-a", shellQuote(r.Authentication["username"]+":"+r.Authentication["password"]))
+	}
+	if r.Body.Type == "multipart" {
+		args.add("--multipart")
+	}
+	if r.Body.Type == "raw" {
+		args.add("--raw", shellQuote(r.Body.Value))
+	}
+
+	args.add(shellQuote(r.Method), shellQuote(u.String()))
+	for _, k := range sortedMap(r.Headers) {
+		args.add(shellQuote(k + ":" + r.Headers[k]))
+	}
+	if r.AcceptEncoding != "" {
+		args.add(shellQuote("Accept-Encoding:" + r.AcceptEncoding))
+	}
+
+	switch r.Body.Type {
+	case "file":
+		args.add("<", shellQuote(r.Body.Value))
+	case "multipart":
+		parts, err := multipartParts(r.Body)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, p := range parts {
+			if p.Type == "file" {
+				args.add(shellQuote(p.Name + "@" + p.Value))
+			} else {
+				args.add(shellQuote(p.Name + "=" + p.Value))
+			}
+		}
+	}
+
+	return []byte(args.String()), warnings, nil
+}
+
+// command is a shell command with each option (and its value) on
+// its own line. The words should already be quoted.
+type command [][]string
+
+func (c *command) add(words ...string) {
+	*c = append(*c, words)
+}
+
+func (c command) String() string {
+	lines := []string{}
+	for _, words := range c {
+		lines = append(lines, strings.Join(words, " "))
+	}
+	return strings.Join(lines, " \\\n  ")
+}
+
+// exportRaw writes the request as it would be sent over HTTP/1.1.
+func exportRaw(r Request) ([]byte, error) {
+	// Multipart bodies print as they are read, so we don't show
+	// anything until the body is read.
+	color.SetOutput(ioutil.Discard)
+	defer color.ResetOutput()
+	req, _, err := newHTTPRequest(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Read the body so the length is known instead of chunking.
+	if req.Body != nil {
+		body, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("reading body: %v", err)
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		req.ContentLength = int64(len(body))
+	}
+
+	buf := &bytes.Buffer{}
+	if err := req.Write(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// exportGo writes a program that makes the request with net/http.
+func exportGo(r Request) ([]byte, []string, error) {
+	u, socket, err := requestURL(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	warnings := []string{}
+	imports := map[string]bool{"fmt": true, "io": true, "net/http": true, "os": true}
+	b := &bytes.Buffer{}
+	p := func(format string, args ...interface{}) {
+		fmt.Fprintf(b, format+"\n", args...)
+	}
+
+	p("func main() {")
+	switch r.Body.Type {
+	case "":
+		p("var body io.Reader")
+	case "raw":
+		imports["strings"] = true
+		p("body := strings.NewReader(%q)", r.Body.Value)
+	case "file":
+		p("body, err := os.Open(%q)", r.Body.Value)
+		p("if err != nil { panic(err) }")
+		p("defer body.Close()")
+	case "multipart":
+		parts, err := multipartParts(r.Body)
+		if err != nil {
+			return nil, nil, err
+		}
+		imports["bytes"] = true
+		imports["mime/multipart"] = true
+		p("body := &bytes.Buffer{}")
+		p("mw := multipart.NewWriter(body)")
+		for _, part := range parts {
+			if part.Type == "file" {
+				imports["path/filepath"] = true
+				p("{")
+				p("f, err := os.Open(%q)", part.Value)
+				p("if err != nil { panic(err) }")
+				p("w, err := mw.CreateFormFile(%q, filepath.Base(%q))", part.Name, part.Value)
+				p("if err != nil { panic(err) }")
+				p("if _, err := io.Copy(w, f); err != nil { panic(err) }")
+				p("f.Close()")
+				p("}")
+			} else {
+				p("if err := mw.WriteField(%q, %q); err != nil { panic(err) }", part.Name, part.Value)
+			}
+		}
+		p("mw.Close()")
+	default:
+		return nil, nil, fmt.Errorf("unexpected body type: %v", r.Body.Type)
+	}
+
+	p("req, err := http.NewRequest(%q, %q, body)", r.Method, u.String())
+	p("if err != nil { panic(err) }")
+	for _, k := range sortedMap(r.Headers) {
+		p("req.Header.Set(%q, %q)", k, r.Headers[k])
+	}
+	if r.AcceptEncoding != "" {
+		p("req.Header.Set(%q, %q)", "Accept-Encoding", r.AcceptEncoding)
+	}
+	if r.Body.Type == "multipart" {
+		p("req.Header.Set(\"Content-Type\", mw.FormDataContentType())")
+	}
+	switch strings.ToLower(r.Authentication["type"]) {
+	case "bearer":
+		p("req.Header.Set(\"Authorization\", %q)", "Bearer "+r.Authentication["token"])
+	case "basic":
+		p("req.SetBasicAuth(%q, %q)", r.Authentication["username"], r.Authentication["password"])
+	}
+
+	client := "http.DefaultClient"
+	if socket != "" || r.TLS != (TLSOptions{}) {
+		client = "client"
+		imports["crypto/tls"] = true
+		p("tlsConfig := &tls.Config{InsecureSkipVerify: %v}", r.TLS.Insecure)
+		if r.TLS.Cert != "" {
+			key := r.TLS.Key
+			if key == "" {
+				key = r.TLS.Cert
+			}
+			p("cert, err := tls.LoadX509KeyPair(%q, %q)", r.TLS.Cert, key)
+			p("if err != nil { panic(err) }")
+			p("tlsConfig.Certificates = []tls.Certificate{cert}")
+		}
+		if r.TLS.CA != "" {
+			imports["crypto/x509"] = true
+			p("ca, err := os.ReadFile(%q)", r.TLS.CA)
+			p("if err != nil { panic(err) }")
+			p("tlsConfig.RootCAs = x509.NewCertPool()")
+			p("tlsConfig.RootCAs.AppendCertsFromPEM(ca)")
+		}
+		p("transport := &http.Transport{TLSClientConfig: tlsConfig}")
+		if socket != "" {
+			imports["context"] = true
+			imports["net"] = true
+			p("transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {")
+			p("return (&net.Dialer{}).DialContext(ctx, \"unix\", %q)", socket)
+			p("}")
+		}
+		p("client := &http.Client{Transport: transport}")
+	}
+	if r.Protocol == ProtocolH2C {
+		warnings = append(warnings, "h2c needs golang.org/x/net/http2, so HTTP/1.1 is used")
+	}
+
+	p("resp, err := %v.Do(req)", client)
+	p("if err != nil { panic(err) }")
+	p("defer resp.Body.Close()")
+	p("fmt.Println(resp.Proto, resp.Status)")
+	p("io.Copy(os.Stdout, resp.Body)")
+	p("}")
+
+	names := []string{}
+	for i := range imports {
+		names = append(names, i)
+	}
+	sort.Strings(names)
+	src := &bytes.Buffer{}
+	fmt.Fprintf(src, "package main\n\nimport (\n")
+	for _, i := range names {
+		fmt.Fprintf(src, "%q\n", i)
+	}
+	fmt.Fprintf(src, ")\n\n%s", b.Bytes())
+
+	out, err := format.Source(src.Bytes())
+	if err != nil {
+		return nil, nil, fmt.Errorf("formatting go: %v", err)
+	}
+	return out, warnings, nil
+}
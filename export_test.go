@@ -0,0 +1,28 @@
+package main
+
+import "testing"
+
+func TestExportCurl(t *testing.T) {
+	tests := []struct {
+		name string
+		req  Request
+		want string
+	}{
+		{"get", Request{Method: "GET", URL: "http://example.com/a"}, "curl http://example.com/a"},
+		{"head", Request{Method: "HEAD", URL: "http://example.com/a"}, "curl -I http://example.com/a"},
+		{"delete", Request{Method: "DELETE", URL: "http://example.com/a"}, "curl -X DELETE http://example.com/a"},
+		{"insecure", Request{Method: "GET", URL: "https://example.com/a", TLS: TLSOptions{Insecure: true}}, "curl https://example.com/a \\\n  -k"},
+		{"query", Request{Method: "GET", URL: "http://example.com/a", Query: map[string]string{"q": "a b"}}, "curl 'http://example.com/a?q=a+b'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, err := exportCurl(tt.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// JSONPath is a parsed filter expression. It's a subset of JSONPath
+// and jq: an optional leading $, keys as .key or ["key"], array
+// indexes as [n] (negative counts from the end), and [*] or [] to
+// select every element of an array or value of an object. For
+// example, $.items[*].id and .items[].id are the same.
+type JSONPath []pathSegment
+
+type pathSegment struct {
+	key      string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+func ParseJSONPath(s string) (JSONPath, error) {
+	p := JSONPath{}
+	s = strings.TrimSpace(s)
+	s = strings.TrimPrefix(s, "$")
+
+	for len(s) > 0 {
+		switch s[0] {
+		case '.':
+			s = s[1:]
+			if strings.HasPrefix(s, "*") {
+				p = append(p, pathSegment{wildcard: true})
+				s = s[1:]
+				continue
+			}
+			end := strings.IndexAny(s, ".[")
+			if end < 0 {
+				end = len(s)
+			}
+			if end > 0 {
+				p = append(p, pathSegment{key: s[:end]})
+			}
+			s = s[end:]
+
+		case '[':
+			end := strings.Index(s, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("missing ']' in '%v'", s)
+			}
+			inner := strings.TrimSpace(s[1:end])
+			s = s[end+1:]
+			switch {
+			case inner == "" || inner == "*":
+				p = append(p, pathSegment{wildcard: true})
+			case strings.HasPrefix(inner, `"`) || strings.HasPrefix(inner, "'"):
+				p = append(p, pathSegment{key: strings.Trim(inner, `"'`)})
+			default:
+				n, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, fmt.Errorf("invalid index '%v'", inner)
+				}
+				p = append(p, pathSegment{index: n, isIndex: true})
+			}
+
+		default:
+			// Allow a bare key at the start (e.g. items[0]).
+			if len(p) > 0 {
+				return nil, fmt.Errorf("unexpected '%v'", s)
+			}
+			s = "." + s
+		}
+	}
+	return p, nil
+}
+
+// HasWildcard determines if the path can match more than one value.
+func (p JSONPath) HasWildcard() bool {
+	for _, seg := range p {
+		if seg.wildcard {
+			return true
+		}
+	}
+	return false
+}
+
+// Select returns all of the values in v the path matches. The value
+// should come from decodeJSON.
+func (p JSONPath) Select(v interface{}) []interface{} {
+	if len(p) == 0 {
+		return []interface{}{v}
+	}
+
+	seg, rest := p[0], p[1:]
+	results := []interface{}{}
+	for _, c := range seg.children(v) {
+		results = append(results, rest.Select(c)...)
+	}
+	return results
+}
+
+// Filter applies the path to v. Paths that can match more than one
+// value return an array of the matches. Otherwise the single match
+// is returned, or nil if nothing matched.
+func (p JSONPath) Filter(v interface{}) interface{} {
+	results := p.Select(v)
+	if p.HasWildcard() {
+		return results
+	}
+	if len(results) == 0 {
+		return nil
+	}
+	return results[0]
+}
+
+// Delete removes the values the path matches from v and returns the
+// result. Objects are changed in place.
+func (p JSONPath) Delete(v interface{}) interface{} {
+	if len(p) == 0 {
+		return nil
+	}
+
+	seg, rest := p[0], p[1:]
+	switch v := v.(type) {
+	case *jsonObject:
+		if seg.isIndex {
+			return v
+		}
+		keys := []string{seg.key}
+		if seg.wildcard {
+			keys = append([]string{}, v.keys...)
+		}
+		for _, k := range keys {
+			c, ok := v.Get(k)
+			if !ok {
+				continue
+			}
+			if len(rest) == 0 {
+				v.Delete(k)
+			} else {
+				v.Set(k, rest.Delete(c))
+			}
+		}
+	case []interface{}:
+		if seg.wildcard {
+			if len(rest) == 0 {
+				return []interface{}{}
+			}
+			for x := range v {
+				v[x] = rest.Delete(v[x])
+			}
+			return v
+		}
+		x, ok := seg.arrayIndex(v)
+		if !ok {
+			return v
+		}
+		if len(rest) == 0 {
+			return append(v[:x:x], v[x+1:]...)
+		}
+		v[x] = rest.Delete(v[x])
+	}
+	return v
+}
+
+func (seg pathSegment) children(v interface{}) []interface{} {
+	switch v := v.(type) {
+	case *jsonObject:
+		if seg.wildcard {
+			c := []interface{}{}
+			for _, k := range v.keys {
+				c = append(c, v.values[k])
+			}
+			return c
+		}
+		if c, ok := v.Get(seg.key); ok && !seg.isIndex {
+			return []interface{}{c}
+		}
+	case []interface{}:
+		if seg.wildcard {
+			return v
+		}
+		if x, ok := seg.arrayIndex(v); ok {
+			return []interface{}{v[x]}
+		}
+	}
+	return nil
+}
+
+func (seg pathSegment) arrayIndex(a []interface{}) (int, bool) {
+	if !seg.isIndex {
+		return 0, false
+	}
+	x := seg.index
+	if x < 0 {
+		x += len(a)
+	}
+	return x, x >= 0 && x < len(a)
+}
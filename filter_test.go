@@ -0,0 +1,177 @@
+package main
+
+import (
+	"flag"
+	"strings"
+	"testing"
+
+	"github.com/urfave/cli/v2"
+)
+
+const filterBody = `{"items": [{"id": 1, "name": "a", "tags": ["x"]}, {"id": 2, "name": "b", "tags": []}], "total": 2, "a.b": {"c": true}}`
+
+func TestParseJSONPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want JSONPath
+		err  string
+	}{
+		{"", JSONPath{}, ""},
+		{"$", JSONPath{}, ""},
+		{".", JSONPath{}, ""},
+		{"$.items[0].id", JSONPath{{key: "items"}, {index: 0, isIndex: true}, {key: "id"}}, ""},
+		{".items[].id", JSONPath{{key: "items"}, {wildcard: true}, {key: "id"}}, ""},
+		{"items[*]", JSONPath{{key: "items"}, {wildcard: true}}, ""},
+		{"$.*", JSONPath{{wildcard: true}}, ""},
+		{`$["a.b"]['c']`, JSONPath{{key: "a.b"}, {key: "c"}}, ""},
+		{"$.items[-1]", JSONPath{{key: "items"}, {index: -1, isIndex: true}}, ""},
+		{"$.items[0", nil, "missing ']' in '[0'"},
+		{"$.items[x]", nil, "invalid index 'x'"},
+		{"$.items[0]id", nil, "unexpected 'id'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, err := ParseJSONPath(tt.path)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("got error %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+			for x := range got {
+				if got[x] != tt.want[x] {
+					t.Errorf("got %+v, want %+v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestJSONPathFilter(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		path string
+		want string
+	}{
+		{"root", filterBody, "$", filterBody},
+		{"key", filterBody, "$.total", `2`},
+		{"nested", filterBody, "$.items[0].name", `"a"`},
+		{"wildcard", filterBody, "$.items[*].id", `[1,2]`},
+		{"jq wildcard", filterBody, ".items[].id", `[1,2]`},
+		{"bare key", filterBody, "items[1].id", `2`},
+		{"negative index", filterBody, "$.items[-1].name", `"b"`},
+		{"quoted key", filterBody, `$["a.b"].c`, `true`},
+		{"object wildcard", `{"a": 1, "b": 2}`, "$.*", `[1,2]`},
+		{"nested wildcard", filterBody, "$.items[*].tags[*]", `["x"]`},
+		{"array root", `[{"id": 1}, {"id": 2}]`, "$[*].id", `[1,2]`},
+		{"array root index", `[{"id": 1}, {"id": 2}]`, "[0]", `{"id":1}`},
+		{"missing key", filterBody, "$.missing", `null`},
+		{"missing nested key", filterBody, "$.items[0].missing", `null`},
+		{"missing wildcard", filterBody, "$.items[*].missing", `[]`},
+		{"out of range", filterBody, "$.items[2]", `null`},
+		{"out of range negative", filterBody, "$.items[-3]", `null`},
+		{"index of object", filterBody, "$[0]", `null`},
+		{"key of array", filterBody, "$.items.id", `null`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := ParseJSONPath(tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			v, err := decodeJSON([]byte(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := decodeJSON([]byte(tt.want))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := string(encodeJSON(p.Filter(v), "", PlainPalette))
+			if got != string(encodeJSON(want, "", PlainPalette)) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJSONPathDelete(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		path string
+		want string
+	}{
+		{"key", filterBody, "$.total", `{"items":[{"id":1,"name":"a","tags":["x"]},{"id":2,"name":"b","tags":[]}],"a.b":{"c":true}}`},
+		{"nested", filterBody, "$.items[*].tags", `{"items":[{"id":1,"name":"a"},{"id":2,"name":"b"}],"total":2,"a.b":{"c":true}}`},
+		{"index", filterBody, "$.items[0]", `{"items":[{"id":2,"name":"b","tags":[]}],"total":2,"a.b":{"c":true}}`},
+		{"negative index", `[1, 2, 3]`, "$[-1]", `[1,2]`},
+		{"every element", `[1, 2, 3]`, "$[*]", `[]`},
+		{"every value", `{"a": 1, "b": {"c": 2}}`, "$.*", `{}`},
+		{"quoted key", filterBody, `$["a.b"].c`, `{"items":[{"id":1,"name":"a","tags":["x"]},{"id":2,"name":"b","tags":[]}],"total":2,"a.b":{}}`},
+		{"missing key", `{"a": 1}`, "$.b.c", `{"a":1}`},
+		{"out of range", `[1, 2]`, "$[2]", `[1,2]`},
+		{"index of object", `{"a": 1}`, "$[0]", `{"a":1}`},
+		{"root", `{"a": 1}`, "$", `null`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := ParseJSONPath(tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			v, err := decodeJSON([]byte(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(encodeJSON(p.Delete(v), "", PlainPalette)); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatBodyFilter(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter string
+		pretty bool
+		body   string
+		want   string
+		err    string
+	}{
+		{"object", "$.items[*].id", false, filterBody, `[1,2]`, ""},
+		{"array root", "$[1].name", false, `[{"name": "a"}, {"name": "b"}]`, `"b"`, ""},
+		{"pretty", "$.items[0].tags", true, filterBody, "[\n  \"x\"\n]", ""},
+		{"missing", "$.missing", false, filterBody, `null`, ""},
+		{"not json", "$.a", false, `<a/>`, `<a/>`, "filtering body"},
+		{"invalid filter", "$.a[", false, filterBody, filterBody, "parsing filter '$.a['"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flags := flag.NewFlagSet("test", flag.ContinueOnError)
+			flags.String("filter", tt.filter, "")
+			flags.Bool("json", tt.pretty, "")
+			flags.Int("indent", 2, "")
+			ctx := cli.NewContext(cli.NewApp(), flags, nil)
+			got, err := formatBody(ctx, "application/json", []byte(tt.body))
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("got error %v, want %v", err, tt.err)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
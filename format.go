@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"mime"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+)
+
+// formatBody returns the body as it should be shown. JSON bodies are
+// filtered and pretty printed if we were asked to. Other bodies are
+// only pretty printed.
+func formatBody(ctx *cli.Context, contentType string, body []byte) ([]byte, error) {
+	filter := ctx.String("filter")
+	indent := indentString(ctx)
+	mt, _, _ := mime.ParseMediaType(contentType)
+	pretty := (ctx.Bool("json") || ctx.Bool("pretty")) && isJSONType(mt)
+	if filter == "" && !pretty {
+		if ctx.Bool("pretty") {
+			return prettyBody(contentType, body, indent, PlainPalette), nil
+		}
+		return body, nil
+	}
+
+	v, err := decodeJSON(body)
+	if err != nil {
+		if filter != "" {
+			return body, fmt.Errorf("filtering body: %v", err)
+		}
+		return body, nil
+	}
+
+	// Include and exclude only work on the top level of objects.
+	if o, ok := v.(*jsonObject); ok && pretty {
+		if include := ctx.String("include"); include != "" {
+			n := newJSONObject()
+			for _, part := range strings.Split(include, ",") {
+				if v, ok := o.Get(part); ok {
+					n.Set(part, v)
+				}
+			}
+			v = n
+		} else if exclude := ctx.String("exclude"); exclude != "" {
+			for _, part := range strings.Split(exclude, ",") {
+				o.Delete(part)
+			}
+		}
+	}
+
+	if filter != "" {
+		p, err := ParseJSONPath(filter)
+		if err != nil {
+			return body, fmt.Errorf("parsing filter '%v': %v", filter, err)
+		}
+		v = p.Filter(v)
+	}
+
+	if pretty {
+		return encodeJSON(v, indent, PlainPalette), nil
+	}
+	return encodeJSON(v, "", PlainPalette), nil
+}
+
+// indentString is the indentation for pretty printed bodies.
+func indentString(ctx *cli.Context) string {
+	return strings.Repeat(" ", ctx.Int("indent"))
+}
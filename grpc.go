@@ -0,0 +1,358 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gookit/color"
+	"github.com/urfave/cli/v2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"gopkg.in/yaml.v3"
+)
+
+// GRPCOptions is the method to call for grpc requests. The message
+// sent is the value of the body, as YAML or JSON. The descriptors
+// come from the protoset file (protoc --descriptor_set_out
+// --include_imports) or from server reflection if it isn't given.
+//
+// The URL is the address of the server. The grpc scheme is
+// plaintext and grpcs uses TLS (e.g. grpcs://api.example.com:443).
+type GRPCOptions struct {
+	Service  string `yaml:"service"`
+	Method   string `yaml:"method"`
+	Protoset string `yaml:"protoset,omitempty"`
+}
+
+func runGRPC(ctx *cli.Context, name string, r Request, opts TransportOptions, prefs map[string]string) (*Response, error) {
+	in, out, err := createRawFiles(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+	defer out.Close()
+
+	opts.TLS, err = newTLSConfig(prefs, r.TLS)
+	if err != nil {
+		return nil, err
+	}
+	opts.TLS.NextProtos = []string{"h2"}
+
+	// Figure out where we are connecting to.
+	u, err := url.Parse(r.URL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing url: %v", err)
+	}
+	secure := false
+	switch u.Scheme {
+	case "grpcs", "https":
+		secure = true
+	case "unix":
+		opts.Socket = unixSocketURL(u)
+		color.Blue.Printf("<unix socket '%v'>\n", opts.Socket)
+	}
+	addr := u.Host
+	if u.Port() == "" {
+		port := "80"
+		if secure {
+			port = "443"
+		}
+		addr = net.JoinHostPort(u.Hostname(), port)
+	}
+	fullMethod := "/" + r.GRPC.Service + "/" + r.GRPC.Method
+	color.Blue.Printf("%v %v%v\n", strings.ToUpper(u.Scheme), addr, fullMethod)
+
+	// The proxy of the environment is tunneled through like it is for
+	// HTTPS.
+	var proxy *url.URL
+	if opts.Proxy != nil && opts.Socket == "" {
+		scheme := "http"
+		if secure {
+			scheme = "https"
+		}
+		proxy, err = opts.Proxy.Proxy(&http.Request{URL: &url.URL{Scheme: scheme, Host: addr}})
+		if err != nil {
+			return nil, fmt.Errorf("finding proxy: %v", err)
+		}
+		if proxy != nil {
+			color.Blue.Printf("<proxy '%v'>\n", proxy.Redacted())
+		}
+	}
+
+	// We do TLS in our dialer so the raw files contain the
+	// plaintext frames, which is why grpc thinks it's insecure.
+	d := NewHelperDialer(in, out, opts)
+	conn, err := grpc.NewClient("passthrough:///"+addr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			if proxy != nil {
+				cfg := opts.TLS
+				if !secure {
+					cfg = nil
+				}
+				return d.DialProxyContext(ctx, proxy, addr, cfg)
+			}
+			if secure {
+				return d.DialTLSContext(ctx, "tcp", addr, opts.TLS)
+			}
+			return d.DialContext(ctx, "tcp", addr)
+		}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("connecting: %v", err)
+	}
+	defer conn.Close()
+
+	callCtx, cancel := context.WithCancel(context.Background())
+	if t := ctx.Duration("timeout"); t > 0 {
+		callCtx, cancel = context.WithTimeout(callCtx, t)
+	}
+	defer cancel()
+
+	// Headers and authentication are sent as metadata.
+	header := http.Header{}
+	for k, v := range r.Headers {
+		header.Add(k, v)
+		color.Blue.Printf("%v: %v\n", k, v)
+	}
+	addAuthentication(header, r.Authentication)
+	md := metadata.MD{}
+	for k, v := range header {
+		md.Append(k, v...)
+	}
+	callCtx = metadata.NewOutgoingContext(callCtx, md)
+
+	// Find the method and create the message.
+	method, err := findGRPCMethod(callCtx, conn, r.GRPC)
+	if err != nil {
+		return nil, err
+	}
+	if method.IsStreamingClient() {
+		return nil, fmt.Errorf("client streaming methods aren't supported: %v", fullMethod)
+	}
+	msg, err := newGRPCMessage(method.Input(), r.Body.Value)
+	if err != nil {
+		return nil, fmt.Errorf("creating message: %v", err)
+	}
+	color.Blue.Printf("\n%v\n", protojson.Format(msg))
+
+	// Do request
+	start := time.Now()
+	stream, err := conn.NewStream(callCtx, &grpc.StreamDesc{ServerStreams: true}, fullMethod)
+	if err == nil {
+		if err = stream.SendMsg(msg); err == nil {
+			err = stream.CloseSend()
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("making request: %v", err)
+	}
+
+	results := []json.RawMessage{}
+	for {
+		out := dynamicpb.NewMessage(method.Output())
+		if err = stream.RecvMsg(out); err != nil {
+			break
+		}
+		buf, err := protojson.Marshal(out)
+		if err != nil {
+			return nil, fmt.Errorf("marshalling response: %v", err)
+		}
+		results = append(results, buf)
+	}
+	if err == io.EOF {
+		err = nil
+	}
+	st := status.Convert(err)
+
+	// Record the results.
+	response := &Response{
+		Protocol:   "grpc",
+		StatusCode: int(st.Code()),
+		Status:     st.Code().String(),
+		Headers:    map[string]string{},
+	}
+	if st.Message() != "" {
+		response.Status += ": " + st.Message()
+	}
+	hmd, _ := stream.Header()
+	for _, m := range []metadata.MD{hmd, stream.Trailer()} {
+		for k, v := range m {
+			response.setHeader(k, v)
+		}
+	}
+
+	var body interface{} = results
+	if !method.IsStreamingServer() && len(results) == 1 {
+		body = results[0]
+	} else if !method.IsStreamingServer() {
+		body = struct{}{}
+	}
+	buf, err := json.MarshalIndent(body, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("marshalling response: %v", err)
+	}
+	response.Body = string(buf)
+	response.Size = int64(len(buf))
+
+	if st.Code() != 0 {
+		color.Red.Printf("%v\n", response.Status)
+	} else {
+		color.Green.Printf("%v\n", response.Status)
+	}
+	for k, v := range response.Headers {
+		color.Green.Printf("%v: %v\n", k, v)
+	}
+	color.Green.Printf("\n%v\n", response.Body)
+
+	response.Duration = time.Since(start)
+	response.When = time.Now()
+	color.Magenta.Printf("\nduration: %v\n", response.Duration)
+	response.Timings.Total = response.Duration
+	display, err := formatBody(ctx, "application/json", []byte(response.Body))
+	if err != nil {
+		color.Red.Printf("<%v>\n", err)
+	}
+	response.display = string(display)
+	return response, nil
+}
+
+// newGRPCMessage creates a message of the given type from its YAML
+// or JSON representation.
+func newGRPCMessage(desc protoreflect.MessageDescriptor, s string) (*dynamicpb.Message, error) {
+	msg := dynamicpb.NewMessage(desc)
+	if strings.TrimSpace(s) == "" {
+		return msg, nil
+	}
+
+	var v interface{}
+	if err := yaml.Unmarshal([]byte(s), &v); err != nil {
+		return nil, err
+	}
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return msg, protojson.Unmarshal(buf, msg)
+}
+
+// findGRPCMethod finds the descriptor for the method from the
+// protoset or using server reflection.
+func findGRPCMethod(ctx context.Context, conn *grpc.ClientConn, opts GRPCOptions) (protoreflect.MethodDescriptor, error) {
+	var files *protoregistry.Files
+	var err error
+	if opts.Protoset != "" {
+		files, err = loadProtoset(opts.Protoset)
+	} else {
+		files, err = reflectFiles(ctx, conn, opts.Service)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	d, err := files.FindDescriptorByName(protoreflect.FullName(opts.Service))
+	if err != nil {
+		return nil, fmt.Errorf("finding service '%v': %v", opts.Service, err)
+	}
+	sd, ok := d.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("'%v' isn't a service", opts.Service)
+	}
+	md := sd.Methods().ByName(protoreflect.Name(opts.Method))
+	if md == nil {
+		return nil, fmt.Errorf("method '%v' not found in '%v'", opts.Method, opts.Service)
+	}
+	return md, nil
+}
+
+func loadProtoset(path string) (*protoregistry.Files, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading protoset: %v", err)
+	}
+	set := &descriptorpb.FileDescriptorSet{}
+	if err := proto.Unmarshal(buf, set); err != nil {
+		return nil, fmt.Errorf("parsing protoset: %v", err)
+	}
+	files, err := protodesc.NewFiles(set)
+	if err != nil {
+		return nil, fmt.Errorf("loading protoset: %v", err)
+	}
+	return files, nil
+}
+
+// reflectFiles gets the file containing the service and all of its
+// dependencies using server reflection.
+func reflectFiles(ctx context.Context, conn *grpc.ClientConn, service string) (*protoregistry.Files, error) {
+	stream, err := rpb.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("starting server reflection: %v", err)
+	}
+	defer stream.CloseSend()
+
+	protos := map[string]*descriptorpb.FileDescriptorProto{}
+	requested := map[string]bool{}
+	req := &rpb.ServerReflectionRequest{
+		MessageRequest: &rpb.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: service},
+	}
+	for req != nil {
+		if err := stream.Send(req); err != nil {
+			return nil, fmt.Errorf("server reflection: %v", err)
+		}
+		resp, err := stream.Recv()
+		if err != nil {
+			return nil, fmt.Errorf("server reflection: %v", err)
+		}
+		if e := resp.GetErrorResponse(); e != nil {
+			return nil, fmt.Errorf("server reflection: %v", e.ErrorMessage)
+		}
+		for _, buf := range resp.GetFileDescriptorResponse().GetFileDescriptorProto() {
+			fd := &descriptorpb.FileDescriptorProto{}
+			if err := proto.Unmarshal(buf, fd); err != nil {
+				return nil, fmt.Errorf("server reflection: %v", err)
+			}
+			protos[fd.GetName()] = fd
+		}
+
+		// Ask for any dependencies we don't have yet.
+		req = nil
+		for _, fd := range protos {
+			for _, dep := range fd.GetDependency() {
+				if _, ok := protos[dep]; !ok && !requested[dep] && req == nil {
+					requested[dep] = true
+					req = &rpb.ServerReflectionRequest{
+						MessageRequest: &rpb.ServerReflectionRequest_FileByFilename{FileByFilename: dep},
+					}
+				}
+			}
+		}
+	}
+
+	set := &descriptorpb.FileDescriptorSet{}
+	for _, fd := range protos {
+		set.File = append(set.File, fd)
+	}
+	files, err := protodesc.NewFiles(set)
+	if err != nil {
+		return nil, fmt.Errorf("server reflection: %v", err)
+	}
+	return files, nil
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/urfave/cli/v2"
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// testHealthServer answers Check with the service that was asked for
+// and streams two statuses from Watch.
+type testHealthServer struct {
+	healthpb.UnimplementedHealthServer
+}
+
+func (testHealthServer) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	if req.Service != "api" {
+		return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVICE_UNKNOWN}, nil
+	}
+	return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}, nil
+}
+
+func (testHealthServer) Watch(req *healthpb.HealthCheckRequest, stream healthpb.Health_WatchServer) error {
+	for _, s := range []healthpb.HealthCheckResponse_ServingStatus{healthpb.HealthCheckResponse_NOT_SERVING, healthpb.HealthCheckResponse_SERVING} {
+		if err := stream.Send(&healthpb.HealthCheckResponse{Status: s}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// testConnectProxy is an HTTP proxy that tunnels with CONNECT. It
+// refuses to if refuse is set and counts the tunnels it makes.
+type testConnectProxy struct {
+	net.Listener
+	refuse  bool
+	tunnels int32
+}
+
+func newTestConnectProxy(t *testing.T, refuse bool) *testConnectProxy {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &testConnectProxy{Listener: l, refuse: refuse}
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go p.serve(c)
+		}
+	}()
+	return p
+}
+
+func (p *testConnectProxy) serve(c net.Conn) {
+	defer c.Close()
+	br := bufio.NewReader(c)
+	req, err := http.ReadRequest(br)
+	if err != nil {
+		return
+	}
+	if req.Method != http.MethodConnect || p.refuse {
+		io.WriteString(c, "HTTP/1.1 403 Forbidden\r\nContent-Length: 0\r\n\r\n")
+		return
+	}
+	to, err := net.Dial("tcp", req.Host)
+	if err != nil {
+		io.WriteString(c, "HTTP/1.1 502 Bad Gateway\r\nContent-Length: 0\r\n\r\n")
+		return
+	}
+	defer to.Close()
+	atomic.AddInt32(&p.tunnels, 1)
+	io.WriteString(c, "HTTP/1.1 200 Connection established\r\n\r\n")
+	go io.Copy(to, br)
+	io.Copy(c, to)
+}
+
+func (p *testConnectProxy) config(noProxy ...string) *ProxyConfig {
+	return &ProxyConfig{HTTP: &url.URL{Scheme: "http", Host: p.Addr().String()}, NoProxy: noProxy}
+}
+
+func TestRunGRPC(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := grpc.NewServer()
+	healthpb.RegisterHealthServer(s, testHealthServer{})
+	reflection.Register(s)
+	go s.Serve(l)
+	defer s.Stop()
+
+	// The protoset has the health service and no dependencies.
+	dir := t.TempDir()
+	protoset := filepath.Join(dir, "health.protoset")
+	set := &descriptorpb.FileDescriptorSet{
+		File: []*descriptorpb.FileDescriptorProto{protodesc.ToFileDescriptorProto(healthpb.File_grpc_health_v1_health_proto)},
+	}
+	buf, err := proto.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(protoset, buf, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.String("config", dir, "")
+	flags.String("state", filepath.Join(dir, "state"), "")
+	flags.String("environment", "test", "")
+	ctx := cli.NewContext(cli.NewApp(), flags, nil)
+
+	proxy := newTestConnectProxy(t, false)
+	defer proxy.Close()
+	refused := newTestConnectProxy(t, true)
+	defer refused.Close()
+
+	serving := map[string]interface{}{"status": "SERVING"}
+	tests := []struct {
+		name     string
+		method   string
+		protoset string
+		body     string
+		proxy    *ProxyConfig
+		tunnels  int32
+		err      string
+		want     interface{}
+	}{
+		{"unary", "Check", "", "service: api", nil, 0, "", serving},
+		{"unary-json", "Check", "", `{"service": "other"}`, nil, 0, "", map[string]interface{}{"status": "SERVICE_UNKNOWN"}},
+		{"streaming", "Watch", "", "", nil, 0, "", []interface{}{
+			map[string]interface{}{"status": "NOT_SERVING"},
+			serving,
+		}},
+		{"protoset", "Check", protoset, "service: api", nil, 0, "", serving},
+		{"proxy", "Check", "", "service: api", proxy.config(), 1, "", serving},
+		{"proxy refused", "Check", "", "service: api", refused.config(), 0, "403 Forbidden", nil},
+		{"no proxy", "Check", "", "service: api", refused.config("127.0.0.1"), 0, "", serving},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Request{
+				Type: RequestTypeGRPC,
+				URL:  "grpc://" + l.Addr().String(),
+				GRPC: GRPCOptions{Service: "grpc.health.v1.Health", Method: tt.method, Protoset: tt.protoset},
+			}
+			r.Body.Value = tt.body
+			before := atomic.LoadInt32(&proxy.tunnels)
+			resp, err := runGRPC(ctx, tt.name, r, TransportOptions{Proxy: tt.proxy}, map[string]string{})
+			if tunnels := atomic.LoadInt32(&proxy.tunnels) - before; tunnels != tt.tunnels {
+				t.Errorf("got %v tunnels through the proxy, want %v", tunnels, tt.tunnels)
+			}
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got error %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != 0 || resp.Status != "OK" {
+				t.Errorf("got status %v %v", resp.StatusCode, resp.Status)
+			}
+			var got interface{}
+			if err := json.Unmarshal([]byte(resp.Body), &got); err != nil {
+				t.Fatalf("parsing body %q: %v", resp.Body, err)
+			}
+			gotBuf, _ := json.Marshal(got)
+			wantBuf, _ := json.Marshal(tt.want)
+			if string(gotBuf) != string(wantBuf) {
+				t.Errorf("got body %s, want %s", gotBuf, wantBuf)
+			}
+		})
+	}
+}
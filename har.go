@@ -0,0 +1,560 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gookit/color"
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// HAR is an HTTP Archive (1.2).
+type HAR struct {
+	Log HARLog `json:"log"`
+}
+
+type HARLog struct {
+	Version string     `json:"version"`
+	Creator HARCreator `json:"creator"`
+	Entries []HAREntry `json:"entries"`
+}
+
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type HAREntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         HARRequest  `json:"request"`
+	Response        HARResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         HARTimings  `json:"timings"`
+	Comment         string      `json:"comment,omitempty"`
+
+	// Browsers add the messages of websockets.
+	WebSocketMessages []json.RawMessage `json:"_webSocketMessages,omitempty"`
+}
+
+type HARRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	QueryString []HARNameValue `json:"queryString"`
+	PostData    *HARPostData   `json:"postData,omitempty"`
+	HeadersSize int64          `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+type HARResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	Content     HARContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int64          `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type HARPostData struct {
+	MimeType string     `json:"mimeType"`
+	Text     string     `json:"text"`
+	Params   []HARParam `json:"params,omitempty"`
+}
+
+type HARParam struct {
+	Name        string `json:"name"`
+	Value       string `json:"value,omitempty"`
+	FileName    string `json:"fileName,omitempty"`
+	ContentType string `json:"contentType,omitempty"`
+}
+
+type HARContent struct {
+	Size        int64  `json:"size"`
+	Compression int64  `json:"compression,omitempty"`
+	MimeType    string `json:"mimeType"`
+	Text        string `json:"text,omitempty"`
+	Encoding    string `json:"encoding,omitempty"`
+}
+
+// HARTimings are in milliseconds. Phases that didn't happen are -1.
+type HARTimings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	SSL     float64 `json:"ssl"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// headerValue is the value of a saved response header. They are saved
+// as lists (e.g. [application/json]).
+func headerValue(v string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(v, "["), "]")
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// harTimings breaks the timings of a response down the way HAR does.
+// Connect includes the TLS handshake.
+func harTimings(t Timings) HARTimings {
+	h := HARTimings{Blocked: -1, DNS: -1, Connect: -1, SSL: -1}
+	setup := t.DNS + t.Connect + t.TLS
+	if t.DNS > 0 {
+		h.DNS = milliseconds(t.DNS)
+	}
+	if t.Connect > 0 {
+		h.Connect = milliseconds(t.Connect + t.TLS)
+	}
+	if t.TLS > 0 {
+		h.SSL = milliseconds(t.TLS)
+	}
+	if t.FirstByte > 0 {
+		h.Wait = milliseconds(t.FirstByte - setup)
+		h.Receive = milliseconds(t.Total - t.FirstByte)
+	} else {
+		h.Wait = milliseconds(t.Total - setup)
+	}
+	return h
+}
+
+func harHeaders(h http.Header) []HARNameValue {
+	headers := []HARNameValue{}
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range h[k] {
+			headers = append(headers, HARNameValue{Name: k, Value: v})
+		}
+	}
+	return headers
+}
+
+// harCookies are the names of the cookies. Their values are
+// credentials, so they are redacted.
+func harCookies(cookies []*http.Cookie) []HARNameValue {
+	nv := []HARNameValue{}
+	for _, c := range cookies {
+		nv = append(nv, HARNameValue{Name: c.Name, Value: redacted})
+	}
+	return nv
+}
+
+// rawExchange reads the request and the last response from the raw
+// files of a run. They can only be read for HTTP/1.x.
+func rawExchange(dir, name string) (*http.Request, []byte, *http.Response, error) {
+	in, err := os.Open(filepath.Join(dir, filepath.FromSlash(name)+"-request.raw"))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	defer in.Close()
+	req, err := http.ReadRequest(bufio.NewReader(in))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	out, err := os.Open(filepath.Join(dir, filepath.FromSlash(name)+"-response.raw"))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	defer out.Close()
+	var last *http.Response
+	br := bufio.NewReader(out)
+	for {
+		resp, err := http.ReadResponse(br, req)
+		if err != nil {
+			break
+		}
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+		last = resp
+	}
+	if last == nil {
+		return nil, nil, nil, fmt.Errorf("no response in the raw file")
+	}
+	return req, body, last, nil
+}
+
+// harEntry makes an entry for a run. The raw files are used for the
+// exact headers if they are from the run.
+func harEntry(root string, e HistoryEntry, useRaw bool) (HAREntry, error) {
+	r := e.Request
+	resp := e.Response
+	entry := HAREntry{
+		StartedDateTime: resp.When.Add(-resp.Duration).UTC().Format(time.RFC3339Nano),
+		Time:            milliseconds(resp.Duration),
+		Timings:         harTimings(resp.Timings),
+		Comment:         e.Name,
+	}
+
+	var req *http.Request
+	var body []byte
+	var raw *http.Response
+	if useRaw {
+		rr, rb, rs, err := rawExchange(filepath.Join(root, e.Environment), e.Name)
+		if err == nil && rr.Method == strings.ToUpper(r.Method) && rs.StatusCode == resp.StatusCode {
+			req, body, raw = rr, rb, rs
+			req.URL.Scheme, req.URL.Host = "http", req.Host
+			if u, err := url.Parse(r.URL); err == nil {
+				req.URL.Scheme = u.Scheme
+			}
+		}
+	}
+	if req == nil {
+		// Make the request again the way aa would send it.
+		color.SetOutput(ioutil.Discard)
+		var err error
+		req, _, err = newHTTPRequest(r)
+		if err == nil && req.Body != nil {
+			body, err = ioutil.ReadAll(req.Body)
+			req.Body.Close()
+		}
+		color.ResetOutput()
+		if err != nil {
+			return entry, err
+		}
+	}
+
+	// The raw files have the credentials the history doesn't.
+	cookies := harCookies(req.Cookies())
+	redactHeader(req.Header)
+	entry.Request = HARRequest{
+		Method:      req.Method,
+		URL:         req.URL.String(),
+		HTTPVersion: resp.Protocol,
+		Cookies:     cookies,
+		Headers:     harHeaders(req.Header),
+		QueryString: []HARNameValue{},
+		HeadersSize: -1,
+		BodySize:    int64(len(body)),
+	}
+	if raw != nil {
+		entry.Request.HTTPVersion = req.Proto
+	}
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	entry.Request.Headers = append(entry.Request.Headers, HARNameValue{Name: "Host", Value: host})
+	for k, vs := range req.URL.Query() {
+		for _, v := range vs {
+			entry.Request.QueryString = append(entry.Request.QueryString, HARNameValue{Name: k, Value: v})
+		}
+	}
+	sort.SliceStable(entry.Request.QueryString, func(x, y int) bool {
+		return entry.Request.QueryString[x].Name < entry.Request.QueryString[y].Name
+	})
+	if len(body) > 0 {
+		entry.Request.PostData = &HARPostData{MimeType: req.Header.Get("Content-Type"), Text: string(body)}
+	}
+
+	headers := http.Header{}
+	if raw != nil {
+		headers = raw.Header
+	} else {
+		for k := range resp.Headers {
+			for _, v := range resp.header(k) {
+				headers.Add(k, v)
+			}
+		}
+	}
+	cookies = harCookies((&http.Response{Header: headers}).Cookies())
+	redactHeader(headers)
+	status := strings.TrimSpace(strings.TrimPrefix(resp.Status, fmt.Sprintf("%v", resp.StatusCode)))
+	entry.Response = HARResponse{
+		Status:      resp.StatusCode,
+		StatusText:  status,
+		HTTPVersion: resp.Protocol,
+		Cookies:     cookies,
+		Headers:     harHeaders(headers),
+		Content: HARContent{
+			Size:     resp.Size,
+			MimeType: headers.Get("Content-Type"),
+			Text:     resp.Body,
+		},
+		RedirectURL: headers.Get("Location"),
+		HeadersSize: -1,
+		BodySize:    resp.Size,
+	}
+	if resp.Encoding != "" {
+		entry.Response.BodySize = resp.CompressedSize
+		entry.Response.Content.Compression = resp.Size - resp.CompressedSize
+	}
+	if !utf8.ValidString(resp.Body) {
+		entry.Response.Content.Text = base64.StdEncoding.EncodeToString([]byte(resp.Body))
+		entry.Response.Content.Encoding = "base64"
+	}
+	return entry, nil
+}
+
+func historyexport(c *cli.Context) error {
+	if !c.Args().Present() {
+		return cli.Exit(color.Red.Sprintf("export expects at least one request name"), -1)
+	}
+	root := stateRoot(c)
+	har := HAR{Log: HARLog{
+		Version: "1.2",
+		Creator: HARCreator{Name: c.App.Name, Version: c.App.Version},
+		Entries: []HAREntry{},
+	}}
+
+	for _, name := range c.Args().Slice() {
+		entries, err := loadHistory(root, name)
+		if err != nil {
+			return cli.Exit(color.Red.Sprintf("%v", err), -1)
+		}
+
+		// The raw files are from the newest run of each environment.
+		newest := map[string]int{}
+		for x, e := range entries {
+			newest[e.Environment] = x
+		}
+		if !c.Bool("all") {
+			entries = entries[len(entries)-1:]
+			newest = map[string]int{entries[0].Environment: 0}
+		}
+		for x, e := range entries {
+			if e.Request.Type != "" && e.Request.Type != RequestTypeHTTP {
+				fmt.Fprint(os.Stderr, color.Yellow.Sprintf("<%v: only http requests can be exported>\n", name))
+				continue
+			}
+			entry, err := harEntry(root, e, newest[e.Environment] == x)
+			if err != nil {
+				return cli.Exit(color.Red.Sprintf("exporting %v (%v): %v", name, e.ID, err), -1)
+			}
+			har.Log.Entries = append(har.Log.Entries, entry)
+		}
+	}
+	sort.SliceStable(har.Log.Entries, func(x, y int) bool {
+		return har.Log.Entries[x].StartedDateTime < har.Log.Entries[y].StartedDateTime
+	})
+
+	buf, err := json.MarshalIndent(har, "", "  ")
+	if err != nil {
+		return cli.Exit(color.Red.Sprintf("encoding HAR: %v", err), -1)
+	}
+	buf = append(buf, '\n')
+	if f := c.String("file"); f != "" {
+		if err := ioutil.WriteFile(f, buf, 0660); err != nil {
+			return cli.Exit(color.Red.Sprintf("writing %v: %v", f, err), -1)
+		}
+		color.Magenta.Printf("exported %v requests into %v\n", len(har.Log.Entries), f)
+		return nil
+	}
+	os.Stdout.Write(buf)
+	return nil
+}
+
+// harSkippedHeaders are set by aa (or the browser) for each request.
+var harSkippedHeaders = map[string]bool{
+	"host": true, "content-length": true, "connection": true, "keep-alive": true,
+	"transfer-encoding": true, "upgrade": true, "te": true,
+}
+
+// ParseHAREntry converts a captured request to a request.
+func ParseHAREntry(e HAREntry) (Request, []string) {
+	warnings := []string{}
+	hr := e.Request
+	r := Request{
+		Method:         strings.ToUpper(hr.Method),
+		Headers:        map[string]string{},
+		Authentication: map[string]string{},
+		Query:          map[string]string{},
+	}
+
+	u, err := url.Parse(hr.URL)
+	if err != nil {
+		return r, []string{fmt.Sprintf("invalid url: %v", err)}
+	}
+	for k, vs := range u.Query() {
+		r.Query[k] = vs[len(vs)-1]
+		if len(vs) > 1 {
+			warnings = append(warnings, fmt.Sprintf("repeated query parameter '%v'; only the last value is kept", k))
+		}
+	}
+	u.RawQuery, u.Fragment = "", ""
+	r.URL = u.String()
+
+	switch strings.ToLower(hr.HTTPVersion) {
+	case "http/2", "http/2.0", "h2":
+		if u.Scheme == "https" {
+			r.Protocol = ProtocolH2
+		}
+	}
+
+	for _, h := range hr.Headers {
+		name := h.Name
+		lower := strings.ToLower(name)
+		switch {
+		case strings.HasPrefix(name, ":") || harSkippedHeaders[lower]:
+			continue
+		case lower == "accept-encoding":
+			r.AcceptEncoding = h.Value
+			continue
+		case lower == "authorization":
+			if auth, ok := parseAuthorization(h.Value); ok {
+				r.Authentication = auth
+				continue
+			}
+		}
+		// HTTP/2 headers are lower case.
+		name = http.CanonicalHeaderKey(name)
+		if v, ok := r.Headers[name]; ok {
+			if lower == "cookie" {
+				r.Headers[name] = v + "; " + h.Value
+				continue
+			}
+			warnings = append(warnings, fmt.Sprintf("repeated header '%v'; only the last value is kept", name))
+		}
+		r.Headers[name] = h.Value
+	}
+
+	if pd := hr.PostData; pd != nil {
+		mt := strings.ToLower(strings.Split(pd.MimeType, ";")[0])
+		switch {
+		case mt == "multipart/form-data" && len(pd.Params) > 0:
+			parts := []MultiPartPart{}
+			for _, p := range pd.Params {
+				part := MultiPartPart{Type: "raw", Name: p.Name, Value: p.Value}
+				if p.FileName != "" {
+					part.Type, part.Value = "file", p.FileName
+					warnings = append(warnings, fmt.Sprintf("the contents of the file '%v' aren't in the capture", p.FileName))
+				}
+				parts = append(parts, part)
+			}
+			buf, err := yaml.Marshal(parts)
+			if err == nil {
+				r.Body = Body{Type: "multipart", Value: string(buf)}
+			}
+			// The multipart body sets the content type and boundary.
+			for k := range r.Headers {
+				if strings.EqualFold(k, "Content-Type") {
+					delete(r.Headers, k)
+				}
+			}
+		case pd.Text == "" && len(pd.Params) > 0:
+			q := []string{}
+			for _, p := range pd.Params {
+				q = append(q, url.QueryEscape(p.Name)+"="+url.QueryEscape(p.Value))
+			}
+			r.Body = Body{Type: "raw", Value: strings.Join(q, "&")}
+		case pd.Text != "":
+			r.Body = Body{Type: "raw", Value: pd.Text}
+		}
+		if pd.MimeType != "" && r.Body.Type == "raw" {
+			setDefault(r.Headers, "Content-Type", pd.MimeType)
+		}
+	}
+	return r, warnings
+}
+
+// parseAuthorization converts bearer and basic authorization headers.
+func parseAuthorization(v string) (map[string]string, bool) {
+	parts := strings.SplitN(strings.TrimSpace(v), " ", 2)
+	if len(parts) != 2 {
+		return nil, false
+	}
+	switch strings.ToLower(parts[0]) {
+	case "bearer":
+		return map[string]string{"type": "bearer", "token": strings.TrimSpace(parts[1])}, true
+	case "basic":
+		buf, err := base64.StdEncoding.DecodeString(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return nil, false
+		}
+		up := strings.SplitN(string(buf), ":", 2)
+		auth := map[string]string{"type": "basic", "username": up[0]}
+		if len(up) == 2 {
+			auth["password"] = up[1]
+		}
+		return auth, true
+	}
+	return nil, false
+}
+
+func importhar(c *cli.Context) error {
+	if c.Args().Len() != 1 {
+		return cli.Exit(color.Red.Sprintf("import expects a HAR file"), -1)
+	}
+	buf, err := ioutil.ReadFile(c.Args().First())
+	if err != nil {
+		return cli.Exit(color.Red.Sprintf("reading HAR: %v", err), -1)
+	}
+	har := HAR{}
+	if err := json.Unmarshal(bytes.TrimPrefix(buf, []byte("\xef\xbb\xbf")), &har); err != nil {
+		return cli.Exit(color.Red.Sprintf("parsing HAR: %v", err), -1)
+	}
+
+	var match *regexp.Regexp
+	if m := c.String("match"); m != "" {
+		if match, err = regexp.Compile(m); err != nil {
+			return cli.Exit(color.Red.Sprintf("invalid match: %v", err), -1)
+		}
+	}
+
+	requests := []NamedRequest{}
+	warnings := []string{}
+	skipped := 0
+	for _, e := range har.Log.Entries {
+		u := e.Request.URL
+		if !strings.HasPrefix(u, "http://") && !strings.HasPrefix(u, "https://") || (match != nil && !match.MatchString(u)) {
+			skipped++
+			continue
+		}
+		name := requestName(e.Request.Method, u)
+		if len(e.WebSocketMessages) > 0 {
+			warnings = append(warnings, name+": websocket messages not imported")
+		}
+		r, w := ParseHAREntry(e)
+		for _, warning := range w {
+			warnings = append(warnings, name+": "+warning)
+		}
+		requests = append(requests, NamedRequest{Name: name, Request: r})
+	}
+	if skipped > 0 {
+		warnings = append(warnings, fmt.Sprintf("skipped %v entries that didn't match or weren't http", skipped))
+	}
+
+	path := importPath(c)
+	names, err := appendRequests(path, requests)
+	if err != nil {
+		return cli.Exit(color.Red.Sprintf("adding requests to %v: %v", path, err), -1)
+	}
+	printImported(path, "requests", names, warnings)
+	return nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHAREntryRedacts(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "dev")
+	if err := os.MkdirAll(dir, 0770); err != nil {
+		t.Fatal(err)
+	}
+	rawRequest := "GET /me HTTP/1.1\r\nHost: example.com\r\nAuthorization: Bearer secret-token\r\nCookie: session=secret-cookie\r\n\r\n"
+	rawResponse := "HTTP/1.1 200 OK\r\nContent-Type: application/json\r\nSet-Cookie: session=secret-session\r\nContent-Length: 2\r\n\r\n{}"
+	if err := ioutil.WriteFile(filepath.Join(dir, "me-request.raw"), []byte(rawRequest), 0660); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "me-response.raw"), []byte(rawResponse), 0660); err != nil {
+		t.Fatal(err)
+	}
+
+	r := Request{
+		URL:            "http://example.com/me",
+		Method:         "GET",
+		Headers:        map[string]string{"Cookie": "session=secret-cookie"},
+		Authentication: map[string]string{"type": "bearer", "token": "secret-token"},
+	}
+	e := HistoryEntry{RunResult: RunResult{
+		Name:        "me",
+		Environment: "dev",
+		Request:     redactRequest(r),
+		Response: &Response{
+			StatusCode: 200,
+			Status:     "200 OK",
+			Protocol:   "HTTP/1.1",
+			Headers:    map[string]string{"Content-Type": "[application/json]", "Set-Cookie": "[session=secret-session]"},
+			Body:       "{}",
+			When:       time.Now(),
+		},
+	}}
+
+	tests := []struct {
+		name   string
+		useRaw bool
+	}{
+		{"raw", true},
+		{"history", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry, err := harEntry(root, e, tt.useRaw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			buf, err := json.Marshal(entry)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, secret := range []string{"secret-token", "secret-cookie", "secret-session"} {
+				if strings.Contains(string(buf), secret) {
+					t.Errorf("%v is in the entry: %s", secret, buf)
+				}
+			}
+			raw := strings.Contains(string(buf), `"Content-Length"`)
+			if raw != tt.useRaw {
+				t.Errorf("got raw headers %v, want %v: %s", raw, tt.useRaw, buf)
+			}
+		})
+	}
+}
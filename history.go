@@ -0,0 +1,220 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gookit/color"
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// historyTimeFormat sorts the same as time.
+const historyTimeFormat = "20060102T150405.000000000Z"
+
+// HistoryEntry is a saved result of running a request.
+type HistoryEntry struct {
+	ID string
+	RunResult
+}
+
+// historyPath is the folder of the history of the named request in
+// the state root.
+func historyPath(root, name string) string {
+	return filepath.Join(root, HistoryDir, filepath.FromSlash(name))
+}
+
+// saveHistory adds the result to the history of the request.
+func saveHistory(root string, result RunResult) error {
+	dir := historyPath(root, result.Name)
+	if err := createStateDir(root, dir); err != nil {
+		return err
+	}
+	buf, err := yaml.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("marshalling history: %v", err)
+	}
+	id := result.Response.When.UTC().Format(historyTimeFormat)
+	if err := ioutil.WriteFile(filepath.Join(dir, id+".yaml"), buf, 0660); err != nil {
+		return fmt.Errorf("writing history: %v", err)
+	}
+	return nil
+}
+
+// loadHistory returns the history of the named request from oldest
+// to newest.
+func loadHistory(root, name string) ([]HistoryEntry, error) {
+	dir := historyPath(root, name)
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("no history for '%v'", name)
+	} else if err != nil {
+		return nil, fmt.Errorf("reading history: %v", err)
+	}
+
+	entries := []HistoryEntry{}
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), ".yaml") {
+			continue
+		}
+		buf, err := ioutil.ReadFile(filepath.Join(dir, f.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("reading history: %v", err)
+		}
+		e := HistoryEntry{ID: strings.TrimSuffix(f.Name(), ".yaml")}
+		if err := yaml.Unmarshal(buf, &e.RunResult); err != nil {
+			return nil, fmt.Errorf("parsing history '%v': %v", f.Name(), err)
+		}
+		if e.Response == nil {
+			e.Response = &Response{}
+		}
+		entries = append(entries, e)
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no history for '%v'", name)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ID < entries[j].ID })
+	return entries, nil
+}
+
+// findHistory finds an entry by its number in the list (negative
+// numbers count back from the newest) or its ID.
+func findHistory(entries []HistoryEntry, ref string) (int, error) {
+	if n, err := strconv.Atoi(ref); err == nil {
+		x := n - 1
+		if n < 0 {
+			x = len(entries) + n
+		}
+		if x < 0 || x >= len(entries) {
+			return 0, fmt.Errorf("history entry %v out of range (1-%v)", n, len(entries))
+		}
+		return x, nil
+	}
+	for x, e := range entries {
+		if e.ID == ref {
+			return x, nil
+		}
+	}
+	return 0, fmt.Errorf("history entry '%v' not found", ref)
+}
+
+func historylist(c *cli.Context) error {
+	if c.Args().Len() != 1 {
+		return cli.Exit(color.Red.Sprintf("list expects a request name"), -1)
+	}
+	entries, err := loadHistory(stateRoot(c), c.Args().First())
+	if err != nil {
+		return cli.Exit(color.Red.Sprintf("%v", err), -1)
+	}
+	for x, e := range entries {
+		color.Magenta.Printf("%4v ", x+1)
+		fmt.Printf("%v ", e.Response.When.Local().Format(time.RFC3339))
+		color.Blue.Printf("%v ", e.Environment)
+		color.Green.Printf("%v ", e.Response.Status)
+		fmt.Printf("%v %v bytes\n", e.Response.Duration, e.Response.Size)
+	}
+	return nil
+}
+
+func historyshow(c *cli.Context) error {
+	if c.Args().Len() < 1 || c.Args().Len() > 2 {
+		return cli.Exit(color.Red.Sprintf("show expects a request name and an optional entry"), -1)
+	}
+	entries, err := loadHistory(stateRoot(c), c.Args().First())
+	if err != nil {
+		return cli.Exit(color.Red.Sprintf("%v", err), -1)
+	}
+	x := len(entries) - 1
+	if c.Args().Len() == 2 {
+		if x, err = findHistory(entries, c.Args().Get(1)); err != nil {
+			return cli.Exit(color.Red.Sprintf("%v", err), -1)
+		}
+	}
+
+	e := entries[x]
+	color.Magenta.Printf("entry: %v (%v)\n", x+1, e.ID)
+	color.Magenta.Printf("when: %v\n", e.Response.When.Local().Format(time.RFC3339))
+	color.Magenta.Printf("environment: %v\n", e.Environment)
+	color.Magenta.Printf("duration: %v\n\n", e.Response.Duration)
+	color.Blue.Printf("%v %v\n\n", e.Request.Method, e.Request.URL)
+	color.Green.Printf("%v %v\n", e.Response.Protocol, e.Response.Status)
+	for _, k := range sortedHeaders(e.Response.Headers) {
+		color.Green.Printf("%v: %v\n", k, e.Response.Headers[k])
+	}
+	color.Green.Printf("\n")
+	contentType := strings.Trim(e.Response.Headers["Content-Type"], "[]")
+	color.Normal.Printf("%s\n", prettyBody(contentType, []byte(e.Response.Body), "  ", ColorPalette))
+	return nil
+}
+
+func historydiff(c *cli.Context) error {
+	if c.Args().Len() < 1 || c.Args().Len() > 3 {
+		return cli.Exit(color.Red.Sprintf("diff expects a request name and up to two entries"), -1)
+	}
+	entries, err := loadHistory(stateRoot(c), c.Args().First())
+	if err != nil {
+		return cli.Exit(color.Red.Sprintf("%v", err), -1)
+	}
+
+	// By default, compare the newest to the one before it. If only one
+	// entry is given, compare it to the newest.
+	a, b := len(entries)-2, len(entries)-1
+	if c.Args().Len() > 1 {
+		if a, err = findHistory(entries, c.Args().Get(1)); err != nil {
+			return cli.Exit(color.Red.Sprintf("%v", err), -1)
+		}
+	}
+	if c.Args().Len() > 2 {
+		if b, err = findHistory(entries, c.Args().Get(2)); err != nil {
+			return cli.Exit(color.Red.Sprintf("%v", err), -1)
+		}
+	}
+	if a < 0 {
+		return cli.Exit(color.Red.Sprintf("only one history entry for '%v'", c.Args().First()), -1)
+	}
+
+	ea, eb := entries[a], entries[b]
+	color.Magenta.Printf("--- %v %v (%v)\n", a+1, ea.Response.When.Local().Format(time.RFC3339), ea.Environment)
+	color.Magenta.Printf("+++ %v %v (%v)\n", b+1, eb.Response.When.Local().Format(time.RFC3339), eb.Environment)
+
+	same := true
+	if ea.Response.Status != eb.Response.Status {
+		same = false
+		color.Magenta.Printf("\nstatus\n")
+		Difference{Kind: DiffChanged, Path: "status", Old: ea.Response.Status, New: eb.Response.Status}.Print()
+	}
+
+	if diffs := diffMaps(ea.Response.Headers, eb.Response.Headers, c.StringSlice("ignore-header")); len(diffs) > 0 {
+		same = false
+		color.Magenta.Printf("\nheaders\n")
+		for _, d := range diffs {
+			d.Print()
+		}
+	}
+
+	if ea.Response.Body != eb.Response.Body {
+		same = false
+		color.Magenta.Printf("\nbody\n")
+		diffBodies(eb.Response.Headers["Content-Type"], ea.Response.Body, eb.Response.Body)
+	}
+
+	if same {
+		color.Green.Printf("\n<no differences>\n")
+	}
+	return nil
+}
+
+func sortedHeaders(h map[string]string) []string {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// httpMethods are the methods a request line of an .http file can
+// start with.
+var httpMethods = map[string]bool{
+	"GET": true, "HEAD": true, "POST": true, "PUT": true, "DELETE": true, "CONNECT": true,
+	"OPTIONS": true, "TRACE": true, "PATCH": true,
+}
+
+var (
+	httpFileVariable = regexp.MustCompile(`^@([A-Za-z0-9_.\-]+)\s*=\s*(.*)$`)
+	httpFileName     = regexp.MustCompile(`^(?:#|//)\s*@name\s+(\S+)`)
+	httpFileVersion  = regexp.MustCompile(`\s+HTTP/[0-9.]+$`)
+	httpFileBody     = regexp.MustCompile(`^<(@?)\s+(\S.*)$`)
+	httpFileResponse = regexp.MustCompile(`^([A-Za-z0-9_\-]+)\.response\.body\.(.+)$`)
+)
+
+// ParseHTTPFile reads the requests of a VS Code REST Client or
+// JetBrains HTTP Client file. Requests are separated by ###. File
+// variables (@name = value) are substituted, other variables are
+// looked up in the environment and references to the responses of
+// named requests ({{login.response.body.$.token}}) use the saved
+// responses. Response handlers and other scripts are ignored. The
+// prefix is the folder the requests are merged into and dir is the
+// folder of the file, which file bodies (< ./body.json) are relative
+// to. Files with variables (<@ ./body.json) are read into the request
+// so their variables are substituted too.
+func ParseHTTPFile(buf []byte, dir, prefix string) (*Config, error) {
+	c := &Config{Requests: map[string]Request{}}
+	if prefix != "" {
+		prefix += "/"
+	}
+
+	vars := map[string]string{}
+	replace := func(s string) string {
+		return re.ReplaceAllStringFunc(s, func(m string) string {
+			name := strings.TrimSpace(strings.Trim(m, "{}"))
+			if v, ok := vars[name]; ok {
+				return v
+			}
+			if strings.HasPrefix(name, "$") {
+				// System variables aren't supported.
+				return m
+			}
+			if r := httpFileResponse.FindStringSubmatch(name); r != nil {
+				path := strings.TrimPrefix(strings.TrimPrefix(r[2], "$"), ".")
+				path = strings.NewReplacer("[", ".", "]", "").Replace(path)
+				return "{{responses." + prefix + r[1] + "." + path + "}}"
+			}
+			return "{{environment." + name + "}}"
+		})
+	}
+
+	lines := strings.Split(strings.ReplaceAll(string(buf), "\r\n", "\n"), "\n")
+	blocks := [][]string{{}}
+	titles := []string{""}
+	for _, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "###") {
+			blocks = append(blocks, []string{})
+			titles = append(titles, strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(line), "#")))
+			continue
+		}
+		blocks[len(blocks)-1] = append(blocks[len(blocks)-1], line)
+	}
+
+	// File variables can be used by every request, even the ones
+	// before them, so they are collected first.
+	for _, block := range blocks {
+		for _, line := range block {
+			line = strings.TrimSpace(line)
+			if m := httpFileVariable.FindStringSubmatch(line); m != nil {
+				vars[m[1]] = replace(strings.TrimSpace(m[2]))
+				continue
+			}
+			if line != "" && !strings.HasPrefix(line, "#") && !strings.HasPrefix(line, "//") {
+				break
+			}
+		}
+	}
+
+	for x, block := range blocks {
+		name := ""
+		r := Request{
+			Headers:        map[string]string{},
+			Authentication: map[string]string{},
+			Query:          map[string]string{},
+		}
+
+		// Everything before the request line is comments and
+		// variables.
+		y := 0
+		for ; y < len(block); y++ {
+			line := strings.TrimSpace(block[y])
+			if m := httpFileName.FindStringSubmatch(line); m != nil {
+				name = m[1]
+				continue
+			}
+			if httpFileVariable.MatchString(line) {
+				continue
+			}
+			if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "//") {
+				continue
+			}
+			break
+		}
+		if y == len(block) {
+			continue
+		}
+
+		// The request line can be just the URL, which is a GET.
+		line := httpFileVersion.ReplaceAllString(strings.TrimSpace(block[y]), "")
+		parts := strings.SplitN(line, " ", 2)
+		r.Method, r.URL = "GET", line
+		if len(parts) == 2 && httpMethods[strings.ToUpper(parts[0])] {
+			r.Method, r.URL = strings.ToUpper(parts[0]), strings.TrimSpace(parts[1])
+		}
+		y++
+
+		// Long query strings can continue on the following lines.
+		for ; y < len(block); y++ {
+			line := strings.TrimSpace(block[y])
+			if !strings.HasPrefix(line, "?") && !strings.HasPrefix(line, "&") {
+				break
+			}
+			r.URL += line
+		}
+		r.URL = replace(httpFileVersion.ReplaceAllString(r.URL, ""))
+
+		// Headers go until the first empty line.
+		for ; y < len(block); y++ {
+			line := strings.TrimSpace(block[y])
+			if line == "" {
+				y++
+				break
+			}
+			if strings.HasPrefix(line, "#") || strings.HasPrefix(line, "//") {
+				continue
+			}
+			kv := strings.SplitN(line, ":", 2)
+			if len(kv) != 2 {
+				return nil, fmt.Errorf("request %v: invalid header '%v'", x, line)
+			}
+			k, v := strings.TrimSpace(kv[0]), replace(strings.TrimSpace(kv[1]))
+			if strings.EqualFold(k, "Authorization") {
+				if auth, ok := httpFileBasicAuth(v); ok {
+					r.Authentication = auth
+					continue
+				}
+			}
+			r.Headers[k] = v
+		}
+
+		body := []string{}
+		for ; y < len(block); y++ {
+			line := block[y]
+			trimmed := strings.TrimSpace(line)
+			// Skip response handler scripts and redirects of the
+			// response to files.
+			if strings.HasPrefix(trimmed, "> {%") {
+				for y < len(block)-1 && !strings.Contains(block[y], "%}") {
+					y++
+				}
+				continue
+			}
+			if strings.HasPrefix(trimmed, "> ") || strings.HasPrefix(trimmed, ">> ") || strings.HasPrefix(trimmed, ">>! ") {
+				continue
+			}
+			body = append(body, line)
+		}
+		text := strings.TrimSpace(strings.Join(body, "\n"))
+		file := httpFileBody.FindStringSubmatch(text)
+		switch {
+		case file != nil && !strings.Contains(text, "\n"):
+			path := strings.TrimSpace(file[2])
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(dir, path)
+			}
+			r.Body = Body{Type: "file", Value: path}
+			if file[1] == "@" {
+				buf, err := ioutil.ReadFile(path)
+				if err != nil {
+					return nil, fmt.Errorf("request %v: reading body: %v", x, err)
+				}
+				r.Body = Body{Type: "raw", Value: replace(string(buf))}
+			}
+		case text != "":
+			for k, v := range r.Headers {
+				if strings.EqualFold(k, "Content-Type") && strings.Contains(v, "x-www-form-urlencoded") {
+					// Form fields can be split over lines starting with &.
+					text = strings.ReplaceAll(text, "\n&", "&")
+				}
+			}
+			r.Body = Body{Type: "raw", Value: replace(text)}
+		}
+
+		if name == "" {
+			name = cleanName(titles[x])
+		}
+		if name == "" {
+			// Variables (like the host) don't make good names.
+			name = requestName(r.Method, re.ReplaceAllString(r.URL, ""))
+		}
+		unique := name
+		for n := 2; ; n++ {
+			if _, ok := c.Requests[unique]; !ok {
+				break
+			}
+			unique = fmt.Sprintf("%v-%v", name, n)
+		}
+		c.Requests[unique] = r
+	}
+	return c, nil
+}
+
+// httpFileBasicAuth converts the basic authorization of .http files,
+// which can have the username and password unencoded (Basic user:pass
+// or Basic user pass).
+func httpFileBasicAuth(v string) (map[string]string, bool) {
+	parts := strings.Fields(v)
+	if len(parts) < 2 || !strings.EqualFold(parts[0], "basic") {
+		return nil, false
+	}
+	user, pass := "", ""
+	switch {
+	case len(parts) == 3:
+		user, pass = parts[1], parts[2]
+	case strings.Contains(parts[1], ":"):
+		up := strings.SplitN(parts[1], ":", 2)
+		user, pass = up[0], up[1]
+	default:
+		buf, err := base64.StdEncoding.DecodeString(parts[1])
+		if err != nil {
+			return nil, false
+		}
+		up := strings.SplitN(string(buf), ":", 2)
+		user = up[0]
+		if len(up) == 2 {
+			pass = up[1]
+		}
+	}
+	return map[string]string{"type": "basic", "username": user, "password": pass}, true
+}
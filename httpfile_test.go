@@ -0,0 +1,48 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseHTTPFile(t *testing.T) {
+	dir := t.TempDir()
+	body := `{"host": "{{host}}", "token": "{{token}}", "id": "{{login.response.body.$.id}}"}`
+	if err := ioutil.WriteFile(filepath.Join(dir, "vars.json"), []byte(body), 0660); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		file string
+		url  string
+		body Body
+	}{
+		{"file body", "# @name a\nPOST http://example.com\n\n< ./body.json\n", "http://example.com", Body{Type: "file", Value: filepath.Join(dir, "body.json")}},
+		{"absolute file body", "# @name a\nPOST http://example.com\n\n< /tmp/body.json\n", "http://example.com", Body{Type: "file", Value: "/tmp/body.json"}},
+		{
+			"file body with variables", "@host = example.com\n# @name a\nPOST http://example.com\n\n<@ ./vars.json\n", "http://example.com",
+			Body{Type: "raw", Value: `{"host": "example.com", "token": "{{environment.token}}", "id": "{{responses.login.id}}"}`},
+		},
+		{"xml body", "# @name a\nPOST http://example.com\n\n<ping/>\n", "http://example.com", Body{Type: "raw", Value: "<ping/>"}},
+		{"no space", "# @name a\nPOST http://example.com\n\n<body.json\n", "http://example.com", Body{Type: "raw", Value: "<body.json"}},
+		{"variable", "@host = example.com\n\n# @name a\nGET http://{{host}}/a\n", "http://example.com/a", Body{}},
+		{"later variable", "# @name a\nGET http://{{host}}/a\n\n###\n@host = example.com\nGET http://{{host}}/b\n", "http://example.com/a", Body{}},
+		{"environment", "# @name a\nGET http://{{host}}/a\n", "http://{{environment.host}}/a", Body{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := ParseHTTPFile([]byte(tt.file), dir, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := c.Requests["a"]
+			if r.URL != tt.url {
+				t.Errorf("got url %q, want %q", r.URL, tt.url)
+			}
+			if r.Body != tt.body {
+				t.Errorf("got body %+v, want %+v", r.Body, tt.body)
+			}
+		})
+	}
+}
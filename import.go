@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gookit/color"
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultImportFile is the file in the import folder requests are
+// added to.
+const DefaultImportFile = "requests.yaml"
+
+// DefaultEnvironmentFile is the file in the import folder environments
+// are added to.
+const DefaultEnvironmentFile = "environments.yaml"
+
+// NamedRequest is a request and the name it should be saved as.
+type NamedRequest struct {
+	Name    string
+	Request Request
+}
+
+// importFlags are the flags every importer has.
+var importFlags = []cli.Flag{
+	&cli.StringFlag{
+		Name:    "folder",
+		Aliases: []string{"d"},
+		Usage:   "the folder of the config to add the requests to",
+	},
+	&cli.StringFlag{
+		Name:    "file",
+		Aliases: []string{"f"},
+		Value:   DefaultImportFile,
+		Usage:   "the file in the folder to add the requests to",
+	},
+}
+
+// environmentFileFlag is the flag of importers that also add
+// environments.
+var environmentFileFlag = &cli.StringFlag{
+	Name:  "environment-file",
+	Value: DefaultEnvironmentFile,
+	Usage: "the file in the folder to add the environments to",
+}
+
+// importPath is the file imported requests are added to.
+func importPath(c *cli.Context) string {
+	return filepath.Join(configDir(c), filepath.FromSlash(c.String("folder")), c.String("file"))
+}
+
+// appendRequests adds the requests to the requests of the YAML file,
+// creating it if it doesn't exist. Names that are already used get a
+// number added to them. The names the requests were saved as are
+// returned.
+func appendRequests(path string, requests []NamedRequest) ([]string, error) {
+	entries := make([]namedValue, len(requests))
+	for x, r := range requests {
+		entries[x] = namedValue{r.Name, r.Request}
+	}
+	return appendEntries(path, "requests", entries)
+}
+
+// appendEnvironments adds the environments to the YAML file in the
+// same way as appendRequests.
+func appendEnvironments(path string, envs map[string]Environment) ([]string, error) {
+	entries := []namedValue{}
+	for _, name := range sortedEnvironments(envs) {
+		entries = append(entries, namedValue{name, envs[name]})
+	}
+	return appendEntries(path, "environments", entries)
+}
+
+func sortedEnvironments(envs map[string]Environment) []string {
+	names := make([]string, 0, len(envs))
+	for name := range envs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+type namedValue struct {
+	name  string
+	value interface{}
+}
+
+// appendEntries adds the values to a top level mapping (e.g.
+// requests) of the YAML file. Comments and the order of what is
+// already in the file are kept.
+func appendEntries(path, section string, entries []namedValue) ([]string, error) {
+	doc := &yaml.Node{Kind: yaml.DocumentNode}
+	buf, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if len(bytes.TrimSpace(buf)) > 0 {
+		if err := yaml.Unmarshal(buf, doc); err != nil {
+			return nil, fmt.Errorf("parsing %v: %v", path, err)
+		}
+	}
+	if len(doc.Content) == 0 {
+		doc.Content = []*yaml.Node{{Kind: yaml.MappingNode}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%v isn't a mapping", path)
+	}
+
+	// Find (or add) the section.
+	var m *yaml.Node
+	for x := 0; x+1 < len(root.Content); x += 2 {
+		if root.Content[x].Value == section {
+			m = root.Content[x+1]
+		}
+	}
+	if m == nil {
+		m = &yaml.Node{Kind: yaml.MappingNode}
+		root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: section}, m)
+	}
+	if m.Kind != yaml.MappingNode {
+		m.Kind, m.Tag, m.Value = yaml.MappingNode, "", ""
+	}
+
+	used := map[string]bool{}
+	for x := 0; x < len(m.Content); x += 2 {
+		used[m.Content[x].Value] = true
+	}
+
+	names := []string{}
+	for _, e := range entries {
+		name := e.name
+		for n := 2; used[name]; n++ {
+			name = fmt.Sprintf("%v-%v", e.name, n)
+		}
+		used[name] = true
+
+		v := &yaml.Node{}
+		if err := v.Encode(e.value); err != nil {
+			return nil, fmt.Errorf("encoding %v: %v", name, err)
+		}
+		m.Content = append(m.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: name}, v)
+		names = append(names, name)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0770); err != nil {
+		return nil, err
+	}
+	out := &bytes.Buffer{}
+	e := yaml.NewEncoder(out)
+	e.SetIndent(2)
+	if err := e.Encode(doc); err != nil {
+		return nil, err
+	}
+	e.Close()
+	return names, ioutil.WriteFile(path, out.Bytes(), 0660)
+}
+
+var nameCleaner = regexp.MustCompile(`[^a-z0-9]+`)
+
+// requestName makes a name for a request from its method and URL
+// (e.g. get-users-id).
+func requestName(method, rawURL string) string {
+	path := rawURL
+	if u, err := url.Parse(rawURL); err == nil {
+		path = u.Path
+		if strings.Trim(path, "/") == "" {
+			path = u.Hostname()
+		}
+	}
+	name := nameCleaner.ReplaceAllString(strings.ToLower(method+"-"+path), "-")
+	return strings.Trim(name, "-")
+}
+
+// printImported reports what (requests or environments) was imported.
+func printImported(path, what string, names []string, warnings []string) {
+	for _, w := range warnings {
+		color.Yellow.Printf("<%v>\n", w)
+	}
+	for _, n := range names {
+		color.Green.Printf("%v\n", n)
+	}
+	color.Magenta.Printf("imported %v %v into %v\n", len(names), what, path)
+}
+
+// writeImport adds the requests of each folder (relative to the
+// import folder) and the environments to the config, and then reports
+// everything that couldn't be imported as it was.
+func writeImport(c *cli.Context, folders []string, requests map[string][]NamedRequest, envs map[string]Environment, warnings []string) error {
+	base := filepath.Join(configDir(c), filepath.FromSlash(c.String("folder")))
+	for _, folder := range folders {
+		path := filepath.Join(base, filepath.FromSlash(folder), c.String("file"))
+		names, err := appendRequests(path, requests[folder])
+		if err != nil {
+			return cli.Exit(color.Red.Sprintf("adding requests to %v: %v", path, err), -1)
+		}
+		printImported(path, "requests", names, nil)
+	}
+	if len(envs) > 0 {
+		path := filepath.Join(base, c.String("environment-file"))
+		names, err := appendEnvironments(path, envs)
+		if err != nil {
+			return cli.Exit(color.Red.Sprintf("adding environments to %v: %v", path, err), -1)
+		}
+		printImported(path, "environments", names, nil)
+	}
+
+	for _, w := range warnings {
+		color.Yellow.Printf("<%v>\n", w)
+	}
+	if len(warnings) > 0 {
+		color.Yellow.Printf("%v constructs weren't imported or were changed\n", len(warnings))
+	}
+	return nil
+}
+
+func importcurl(c *cli.Context) error {
+	var line string
+	switch c.Args().Len() {
+	case 0:
+		buf, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			return cli.Exit(color.Red.Sprintf("reading stdin: %v", err), -1)
+		}
+		line = string(buf)
+	case 1:
+		line = c.Args().First()
+	}
+
+	args := c.Args().Slice()
+	if line != "" {
+		var err error
+		if args, err = splitShellWords(line); err != nil {
+			return cli.Exit(color.Red.Sprintf("parsing command: %v", err), -1)
+		}
+	}
+
+	r, warnings, err := ParseCurl(args)
+	if err != nil {
+		return cli.Exit(color.Red.Sprintf("parsing curl: %v", err), -1)
+	}
+	name := c.String("name")
+	if name == "" {
+		name = requestName(r.Method, r.URL)
+	}
+
+	path := importPath(c)
+	names, err := appendRequests(path, []NamedRequest{{Name: name, Request: r}})
+	if err != nil {
+		return cli.Exit(color.Red.Sprintf("adding request to %v: %v", path, err), -1)
+	}
+	printImported(path, "requests", names, warnings)
+	return nil
+}
@@ -0,0 +1,489 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gookit/color"
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// insomniaExport is an Insomnia (v4) export. Everything is a resource
+// that points to its parent (workspaces contain folders and
+// environments, folders contain requests and other folders).
+type insomniaExport struct {
+	Type      string             `json:"_type"`
+	Format    int                `json:"__export_format"`
+	Resources []insomniaResource `json:"resources"`
+}
+
+type insomniaResource struct {
+	ID          string  `json:"_id"`
+	Type        string  `json:"_type"`
+	ParentID    string  `json:"parentId"`
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	MetaSortKey float64 `json:"metaSortKey"`
+
+	Method         string             `json:"method"`
+	URL            string             `json:"url"`
+	Body           insomniaBody       `json:"body"`
+	Parameters     []insomniaKeyValue `json:"parameters"`
+	Headers        []insomniaKeyValue `json:"headers"`
+	Authentication insomniaAuth       `json:"authentication"`
+
+	// Data are the variables of environments and Environment the
+	// variables of folders.
+	Data        map[string]interface{} `json:"data"`
+	Environment map[string]interface{} `json:"environment"`
+}
+
+type insomniaKeyValue struct {
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	Disabled bool   `json:"disabled"`
+	Type     string `json:"type"`
+	FileName string `json:"fileName"`
+}
+
+type insomniaBody struct {
+	MimeType string             `json:"mimeType"`
+	Text     string             `json:"text"`
+	Params   []insomniaKeyValue `json:"params"`
+	FileName string             `json:"fileName"`
+}
+
+type insomniaAuth struct {
+	Type     string `json:"type"`
+	Disabled bool   `json:"disabled"`
+	Token    string `json:"token"`
+	Prefix   string `json:"prefix"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Key      string `json:"key"`
+	Value    string `json:"value"`
+	AddTo    string `json:"addTo"`
+}
+
+var (
+	insomniaVariable = regexp.MustCompile(`\{\{\s*([^{}]+?)\s*\}\}`)
+	insomniaTag      = regexp.MustCompile(`\{%\s*([A-Za-z0-9_]+)(.*?)%\}`)
+	insomniaArgument = regexp.MustCompile(`'([^']*)'|"([^"]*)"`)
+	insomniaBase64   = regexp.MustCompile(`^b64::(.*)::46b$`)
+)
+
+// insomniaImport is the result of converting an Insomnia export.
+type insomniaImport struct {
+	Folders      []string
+	Requests     map[string][]NamedRequest
+	Environments map[string]Environment
+	Warnings     []string
+
+	// base is the folder of the config everything is imported into
+	// and names are the names of the requests by their ID, which
+	// response tags refer to. Workspaces are only folders when there
+	// are several of them.
+	base       string
+	resources  map[string]insomniaResource
+	order      []insomniaResource
+	names      map[string]string
+	workspaces bool
+}
+
+func (i *insomniaImport) warn(where, format string, a ...interface{}) {
+	i.Warnings = append(i.Warnings, where+": "+fmt.Sprintf(format, a...))
+}
+
+// ParseInsomnia converts the requests and environments of the
+// Insomnia export. Folders become folders of the config under base.
+func ParseInsomnia(buf []byte, base string) (*insomniaImport, error) {
+	e := insomniaExport{}
+	if err := json.Unmarshal(buf, &e); err != nil {
+		return nil, fmt.Errorf("parsing export: %v", err)
+	}
+	if e.Type != "export" || e.Format != 4 {
+		return nil, fmt.Errorf("not an Insomnia v4 export (type '%v', format %v)", e.Type, e.Format)
+	}
+
+	i := &insomniaImport{
+		Requests:     map[string][]NamedRequest{},
+		Environments: map[string]Environment{},
+		base:         strings.Trim(filepath.ToSlash(base), "/"),
+		resources:    map[string]insomniaResource{},
+		names:        map[string]string{},
+		order:        e.Resources,
+	}
+	workspaces := 0
+	for _, r := range e.Resources {
+		i.resources[r.ID] = r
+		if r.Type == "workspace" {
+			workspaces++
+		}
+	}
+	i.workspaces = workspaces > 1
+
+	// Requests are in the order Insomnia shows them. Their names are
+	// found first so response tags can refer to later requests.
+	requests := []insomniaResource{}
+	for _, r := range e.Resources {
+		switch r.Type {
+		case "request", "websocket_request":
+			requests = append(requests, r)
+		case "grpc_request":
+			i.warn(i.where(r), "gRPC requests not imported")
+		}
+	}
+	sort.SliceStable(requests, func(x, y int) bool {
+		return requests[x].MetaSortKey < requests[y].MetaSortKey
+	})
+	folders := make([]string, len(requests))
+	used := map[string]bool{}
+	for x, r := range requests {
+		folders[x] = i.folder(r.ParentID)
+		name := cleanName(r.Name)
+		if name == "" {
+			// Variables (like the host) don't make good names.
+			name = requestName(r.Method, insomniaTag.ReplaceAllString(insomniaVariable.ReplaceAllString(r.URL, ""), ""))
+		}
+		unique := name
+		for n := 2; used[folders[x]+"/"+unique]; n++ {
+			unique = fmt.Sprintf("%v-%v", name, n)
+		}
+		used[folders[x]+"/"+unique] = true
+		i.names[r.ID] = unique
+	}
+
+	for x, r := range requests {
+		if _, ok := i.Requests[folders[x]]; !ok {
+			i.Folders = append(i.Folders, folders[x])
+		}
+		i.Requests[folders[x]] = append(i.Requests[folders[x]], NamedRequest{Name: i.names[r.ID], Request: i.request(r)})
+	}
+
+	i.environments()
+	return i, nil
+}
+
+// folder is the folder of the config for the Insomnia folder with the
+// ID.
+func (i *insomniaImport) folder(id string) string {
+	parts := []string{}
+	for r, ok := i.resources[id]; ok; r, ok = i.resources[r.ParentID] {
+		if r.Type == "request_group" || (r.Type == "workspace" && i.workspaces) {
+			parts = append([]string{cleanName(r.Name)}, parts...)
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// where is the path of the resource shown in warnings.
+func (i *insomniaImport) where(r insomniaResource) string {
+	name := r.Name
+	if name == "" {
+		name = i.names[r.ID]
+	}
+	parts := []string{name}
+	for p, ok := i.resources[r.ParentID]; ok && p.Type == "request_group"; p, ok = i.resources[p.ParentID] {
+		parts = append([]string{p.Name}, parts...)
+	}
+	return strings.Join(parts, "/")
+}
+
+func (i *insomniaImport) request(ir insomniaResource) Request {
+	where := i.where(ir)
+	r := Request{
+		Method:         strings.ToUpper(ir.Method),
+		Description:    ir.Description,
+		URL:            i.vars(where, ir.URL),
+		Headers:        map[string]string{},
+		Authentication: map[string]string{},
+		Query:          map[string]string{},
+	}
+	if r.Method == "" {
+		r.Method = "GET"
+	}
+	if ir.Type == "websocket_request" {
+		r.Type, r.Method = RequestTypeWebSocket, ""
+		i.warn(where, "websocket messages not imported")
+	}
+
+	for _, kv := range ir.Parameters {
+		i.set(where, "query parameter", r.Query, kv)
+	}
+	for _, kv := range ir.Headers {
+		i.set(where, "header", r.Headers, kv)
+	}
+
+	// Requests without authentication inherit it from their folders,
+	// which can also add headers.
+	auth := ir.Authentication
+	for p, ok := i.resources[ir.ParentID]; ok && p.Type == "request_group"; p, ok = i.resources[p.ParentID] {
+		if auth.Type == "" || auth.Type == "inherit" {
+			auth = p.Authentication
+		}
+		for _, kv := range p.Headers {
+			if _, ok := r.Headers[kv.Name]; !ok {
+				i.set(where, "folder header", r.Headers, kv)
+			}
+		}
+	}
+	i.auth(where, &r, auth)
+	i.body(where, &r, ir.Body)
+	return r
+}
+
+func (i *insomniaImport) set(where, what string, m map[string]string, kv insomniaKeyValue) {
+	if kv.Name == "" {
+		return
+	}
+	if kv.Disabled {
+		i.warn(where, "disabled %v '%v' not imported", what, kv.Name)
+		return
+	}
+	k := i.vars(where, kv.Name)
+	if _, ok := m[k]; ok {
+		i.warn(where, "repeated %v '%v'; only the last value is kept", what, kv.Name)
+	}
+	m[k] = i.vars(where, kv.Value)
+}
+
+func (i *insomniaImport) auth(where string, r *Request, auth insomniaAuth) {
+	if auth.Disabled {
+		if auth.Type != "" {
+			i.warn(where, "disabled %v authentication not imported", auth.Type)
+		}
+		return
+	}
+	switch auth.Type {
+	case "", "none", "inherit":
+	case "bearer":
+		if auth.Prefix != "" && !strings.EqualFold(auth.Prefix, "bearer") {
+			r.Headers["Authorization"] = i.vars(where, auth.Prefix+" "+auth.Token)
+			return
+		}
+		r.Authentication["type"] = "bearer"
+		r.Authentication["token"] = i.vars(where, auth.Token)
+	case "basic":
+		r.Authentication["type"] = "basic"
+		r.Authentication["username"] = i.vars(where, auth.Username)
+		r.Authentication["password"] = i.vars(where, auth.Password)
+	case "apikey":
+		kv := insomniaKeyValue{Name: auth.Key, Value: auth.Value}
+		switch auth.AddTo {
+		case "queryParams":
+			i.set(where, "query parameter", r.Query, kv)
+		case "cookie":
+			r.Headers["Cookie"] = i.vars(where, auth.Key+"="+auth.Value)
+		default:
+			i.set(where, "header", r.Headers, kv)
+		}
+	default:
+		i.warn(where, "%v authentication not imported", auth.Type)
+	}
+}
+
+func (i *insomniaImport) body(where string, r *Request, b insomniaBody) {
+	switch {
+	case b.MimeType == "" && b.Text == "" && len(b.Params) == 0 && b.FileName == "":
+		return
+	case b.MimeType == "application/x-www-form-urlencoded":
+		data := []string{}
+		for _, kv := range b.Params {
+			if kv.Disabled {
+				i.warn(where, "disabled form field '%v' not imported", kv.Name)
+				continue
+			}
+			data = append(data, escapeVars(i.vars(where, kv.Name))+"="+escapeVars(i.vars(where, kv.Value)))
+		}
+		r.Body = Body{Type: "raw", Value: strings.Join(data, "&")}
+	case b.MimeType == "multipart/form-data":
+		parts := []MultiPartPart{}
+		for _, kv := range b.Params {
+			if kv.Disabled {
+				i.warn(where, "disabled form field '%v' not imported", kv.Name)
+				continue
+			}
+			part := MultiPartPart{Type: "raw", Name: i.vars(where, kv.Name), Value: i.vars(where, kv.Value)}
+			if kv.Type == "file" {
+				part.Type, part.Value = "file", kv.FileName
+			}
+			parts = append(parts, part)
+		}
+		buf, err := yaml.Marshal(parts)
+		if err != nil {
+			i.warn(where, "form not imported: %v", err)
+			return
+		}
+		r.Body = Body{Type: "multipart", Value: string(buf)}
+		// The boundary is added when the request is sent.
+		return
+	case b.FileName != "":
+		r.Body = Body{Type: "file", Value: b.FileName}
+	case b.MimeType == "application/graphql":
+		// The text is already the JSON Insomnia sends.
+		r.Body = Body{Type: "raw", Value: i.vars(where, b.Text)}
+		setDefault(r.Headers, "Content-Type", "application/json")
+		return
+	default:
+		r.Body = Body{Type: "raw", Value: i.vars(where, b.Text)}
+	}
+	if b.MimeType != "" {
+		setDefault(r.Headers, "Content-Type", b.MimeType)
+	}
+}
+
+// vars converts the Nunjucks templates of Insomnia. Variables are
+// looked up in the environment ({{ _.host }} is {{environment.host}})
+// and the bodies of other responses ({% response 'body', 'req_1',
+// '$.token' %}) in the saved responses. Other tags aren't supported.
+func (i *insomniaImport) vars(where, s string) string {
+	s = insomniaVariable.ReplaceAllStringFunc(s, func(m string) string {
+		name := strings.TrimPrefix(insomniaVariable.FindStringSubmatch(m)[1], "_.")
+		if strings.ContainsAny(name, " |()[]'\"") {
+			i.warn(where, "expression '%v' not supported", m)
+			return m
+		}
+		return "{{environment." + name + "}}"
+	})
+	return insomniaTag.ReplaceAllStringFunc(s, func(m string) string {
+		tag := insomniaTag.FindStringSubmatch(m)
+		if tag[1] != "response" {
+			i.warn(where, "%v tag not supported", tag[1])
+			return m
+		}
+		args := []string{}
+		for _, a := range insomniaArgument.FindAllStringSubmatch(tag[2], -1) {
+			args = append(args, a[1]+a[2])
+		}
+		if len(args) < 3 || args[0] != "body" {
+			i.warn(where, "only response tags of the body are supported")
+			return m
+		}
+		name, ok := i.names[args[1]]
+		if !ok {
+			i.warn(where, "response tag of the unknown request %v", args[1])
+			return m
+		}
+		path := args[2]
+		if b := insomniaBase64.FindStringSubmatch(path); b != nil {
+			buf, err := base64.StdEncoding.DecodeString(b[1])
+			if err != nil {
+				i.warn(where, "response tag path %v: %v", path, err)
+				return m
+			}
+			path = string(buf)
+		}
+		if !strings.HasPrefix(path, "$") {
+			i.warn(where, "response tag path '%v' isn't JSONPath", path)
+			return m
+		}
+		path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+		path = strings.NewReplacer("[", ".", "]", "").Replace(path)
+		full := strings.Trim(i.base+"/"+i.folder(i.resources[args[1]].ParentID)+"/"+name, "/")
+		if len(args) > 3 && args[3] != "never" {
+			i.warn(where, "response tag of %v uses the saved response; it isn't sent again", name)
+		}
+		return "{{responses." + full + "." + path + "}}"
+	})
+}
+
+// environments converts the sub environments, which include the
+// variables of their base environment. When there are none, the base
+// environment is imported on its own. Variables of folders don't exist
+// in aa, so they are added to every environment that doesn't have
+// them.
+func (i *insomniaImport) environments() {
+	folders := map[string]interface{}{}
+	for _, r := range i.order {
+		if r.Type == "request_group" && len(r.Environment) > 0 {
+			i.warn(i.where(r), "folder variables added to the environments")
+			for k, v := range i.values(r.Name, r.Environment) {
+				folders[k] = v
+			}
+		}
+	}
+
+	for _, base := range i.order {
+		workspace, ok := i.resources[base.ParentID]
+		if base.Type != "environment" || !ok || workspace.Type != "workspace" {
+			continue
+		}
+		values := i.values(base.Name, base.Data)
+		subs := 0
+		for _, sub := range i.order {
+			if sub.Type != "environment" || sub.ParentID != base.ID {
+				continue
+			}
+			subs++
+			env := Environment{}
+			for k, v := range values {
+				env[k] = v
+			}
+			for k, v := range i.values(sub.Name, sub.Data) {
+				env[k] = v
+			}
+			i.Environments[cleanName(sub.Name)] = env
+		}
+		if subs == 0 && (len(base.Data) > 0 || len(folders) > 0) {
+			name := cleanName(workspace.Name)
+			if name == "" {
+				name = "insomnia"
+			}
+			env := Environment{}
+			for k, v := range values {
+				env[k] = v
+			}
+			i.Environments[name] = env
+			i.Warnings = append(i.Warnings, fmt.Sprintf("base environment imported as the '%v' environment", name))
+		}
+	}
+
+	for _, env := range i.Environments {
+		for k, v := range folders {
+			if _, ok := env[k]; !ok {
+				env[k] = v
+			}
+		}
+	}
+}
+
+// values converts the templates in the variables. Values that are null
+// or lists can't be used by aa.
+func (i *insomniaImport) values(where string, data map[string]interface{}) map[string]interface{} {
+	out := map[string]interface{}{}
+	for k, v := range data {
+		switch v := v.(type) {
+		case string:
+			out[k] = i.vars(where, v)
+		case map[string]interface{}:
+			out[k] = i.values(where, v)
+		case nil, []interface{}:
+			i.warn(where, "variable '%v' not imported", k)
+		default:
+			out[k] = v
+		}
+	}
+	return out
+}
+
+func importinsomnia(c *cli.Context) error {
+	if c.Args().Len() != 1 {
+		return cli.Exit(color.Red.Sprintf("import insomnia expects an export file"), -1)
+	}
+	buf, err := ioutil.ReadFile(c.Args().First())
+	if err != nil {
+		return cli.Exit(color.Red.Sprintf("reading export: %v", err), -1)
+	}
+	i, err := ParseInsomnia(buf, c.String("folder"))
+	if err != nil {
+		return cli.Exit(color.Red.Sprintf("%v", err), -1)
+	}
+	return writeImport(c, i.Folders, i.Requests, i.Environments, i.Warnings)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseInsomniaResponseTags(t *testing.T) {
+	resources := func(workspaces int) []byte {
+		rs := []map[string]interface{}{
+			{"_id": "wrk_1", "_type": "workspace", "name": "Shop"},
+			{"_id": "fld_1", "_type": "request_group", "parentId": "wrk_1", "name": "Auth"},
+			{"_id": "req_1", "_type": "request", "parentId": "fld_1", "name": "Login", "method": "POST", "url": "http://example.com/login"},
+			{"_id": "req_2", "_type": "request", "parentId": "wrk_1", "name": "Me", "method": "GET",
+				"url": "http://example.com/me/{% response 'body', 'req_1', 'b64::JC5pZA==::46b', 'never', 60 %}"},
+		}
+		if workspaces > 1 {
+			rs = append(rs, map[string]interface{}{"_id": "wrk_2", "_type": "workspace", "name": "Other"})
+		}
+		buf, err := json.Marshal(map[string]interface{}{"_type": "export", "__export_format": 4, "resources": rs})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return buf
+	}
+
+	tests := []struct {
+		name       string
+		workspaces int
+		folder     string
+		url        string
+	}{
+		{"one workspace", 1, "", "http://example.com/me/{{responses.imported/auth/login.id}}"},
+		{"workspaces", 2, "shop", "http://example.com/me/{{responses.imported/shop/auth/login.id}}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i, err := ParseInsomnia(resources(tt.workspaces), "imported")
+			if err != nil {
+				t.Fatal(err)
+			}
+			requests := i.Requests[tt.folder]
+			if len(requests) != 1 {
+				t.Fatalf("got %v requests in %v, want 1 (%v)", len(requests), tt.folder, i.Requests)
+			}
+			if got := requests[0].Request.URL; got != tt.url {
+				t.Errorf("got %q, want %q", got, tt.url)
+			}
+		})
+	}
+}
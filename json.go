@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// jsonObject is a decoded JSON object that remembers the order of its
+// keys so we can print it the way the server sent it.
+type jsonObject struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func newJSONObject() *jsonObject {
+	return &jsonObject{values: map[string]interface{}{}}
+}
+
+// Get returns the value of the key.
+func (o *jsonObject) Get(k string) (interface{}, bool) {
+	v, ok := o.values[k]
+	return v, ok
+}
+
+// Set sets the value of the key. New keys are added to the end.
+func (o *jsonObject) Set(k string, v interface{}) {
+	if _, ok := o.values[k]; !ok {
+		o.keys = append(o.keys, k)
+	}
+	o.values[k] = v
+}
+
+// Delete removes the key if it exists.
+func (o *jsonObject) Delete(k string) {
+	if _, ok := o.values[k]; !ok {
+		return
+	}
+	delete(o.values, k)
+	for x, key := range o.keys {
+		if key == k {
+			o.keys = append(o.keys[:x], o.keys[x+1:]...)
+			break
+		}
+	}
+}
+
+// decodeJSON decodes a JSON value. Objects are decoded as *jsonObject
+// and numbers as json.Number so neither order nor precision is lost.
+func decodeJSON(body []byte) (interface{}, error) {
+	d := json.NewDecoder(bytes.NewReader(body))
+	d.UseNumber()
+	v, err := decodeJSONValue(d)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := d.Token(); err != io.EOF {
+		return nil, fmt.Errorf("unexpected data after value")
+	}
+	return v, nil
+}
+
+func decodeJSONValue(d *json.Decoder) (interface{}, error) {
+	t, err := d.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch t {
+	case json.Delim('{'):
+		o := newJSONObject()
+		for d.More() {
+			t, err := d.Token()
+			if err != nil {
+				return nil, err
+			}
+			k, ok := t.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid object key %v", t)
+			}
+			v, err := decodeJSONValue(d)
+			if err != nil {
+				return nil, err
+			}
+			o.Set(k, v)
+		}
+		if _, err := d.Token(); err != nil {
+			return nil, err
+		}
+		return o, nil
+	case json.Delim('['):
+		a := []interface{}{}
+		for d.More() {
+			v, err := decodeJSONValue(d)
+			if err != nil {
+				return nil, err
+			}
+			a = append(a, v)
+		}
+		if _, err := d.Token(); err != nil {
+			return nil, err
+		}
+		return a, nil
+	}
+	return t, nil
+}
+
+// encodeJSON writes a value from decodeJSON. An empty indent writes
+// compact JSON.
+func encodeJSON(v interface{}, indent string, p Palette) []byte {
+	buf := &bytes.Buffer{}
+	writeJSON(buf, v, indent, 0, p)
+	return buf.Bytes()
+}
+
+func writeJSON(buf *bytes.Buffer, v interface{}, indent string, depth int, p Palette) {
+	newline := func(depth int) {
+		if indent != "" {
+			buf.WriteString("\n" + strings.Repeat(indent, depth))
+		}
+	}
+	colon := ":"
+	if indent != "" {
+		colon = ": "
+	}
+
+	switch v := v.(type) {
+	case *jsonObject:
+		if len(v.keys) == 0 {
+			buf.WriteString("{}")
+			return
+		}
+		buf.WriteString("{")
+		for x, k := range v.keys {
+			if x > 0 {
+				buf.WriteString(",")
+			}
+			newline(depth + 1)
+			buf.WriteString(p.Key(quoteJSON(k)) + colon)
+			writeJSON(buf, v.values[k], indent, depth+1, p)
+		}
+		newline(depth)
+		buf.WriteString("}")
+	case []interface{}:
+		if len(v) == 0 {
+			buf.WriteString("[]")
+			return
+		}
+		buf.WriteString("[")
+		for x, e := range v {
+			if x > 0 {
+				buf.WriteString(",")
+			}
+			newline(depth + 1)
+			writeJSON(buf, e, indent, depth+1, p)
+		}
+		newline(depth)
+		buf.WriteString("]")
+	case string:
+		buf.WriteString(p.String(quoteJSON(v)))
+	case json.Number:
+		buf.WriteString(p.Number(v.String()))
+	case nil:
+		buf.WriteString(p.Literal("null"))
+	default:
+		// Booleans and anything that didn't come from decodeJSON.
+		b, err := json.Marshal(v)
+		if err != nil {
+			b = []byte(quoteJSON(fmt.Sprint(v)))
+		}
+		buf.WriteString(p.Literal(string(b)))
+	}
+}
+
+// quoteJSON quotes a string without escaping HTML characters.
+func quoteJSON(s string) string {
+	buf := &bytes.Buffer{}
+	e := json.NewEncoder(buf)
+	e.SetEscapeHTML(false)
+	e.Encode(s)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
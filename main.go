@@ -2,15 +2,17 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
-	"encoding/json"
+	"crypto/x509"
+	"encoding/base64"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptrace"
 	"net/url"
 	"os"
-	"path/filepath"
 	"strings"
 	"time"
 
@@ -40,11 +42,15 @@ func main() {
 				Usage:   "the file or folder containting environments, requests, and responses to use",
 			},
 			&cli.StringFlag{
-				Name:     "environment",
-				Aliases:  []string{"env", "e"},
-				EnvVars:  []string{"AA_ENVIRONMENT", "AA_ENV"},
-				Usage:    "the name of the environment to use for string interpolation",
-				Required: true,
+				Name:    "environment",
+				Aliases: []string{"env", "e"},
+				EnvVars: []string{"AA_ENVIRONMENT", "AA_ENV"},
+				Usage:   "the name of the environment to use for string interpolation",
+			},
+			&cli.StringFlag{
+				Name:    "state",
+				EnvVars: []string{"AA_STATE"},
+				Usage:   "the folder responses, raw requests and history are saved in (default: " + DefaultStateDir + " in the config folder)",
 			},
 		},
 		Commands: []*cli.Command{
@@ -56,18 +62,59 @@ func main() {
 						Name:    "run",
 						Aliases: []string{"r"},
 						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:    "output",
+								Aliases: []string{"o"},
+								EnvVars: []string{"AA_RUN_OUTPUT"},
+								Value:   OutputHuman,
+								Usage:   "how to print results: human, json, yaml, raw (body only) or quiet",
+							},
 							&cli.BoolFlag{
 								Name:    "json",
 								Aliases: []string{"j"},
 								EnvVars: []string{"AA_RUN_JSON"},
 								Usage:   "pretty print json responses",
 							},
+							&cli.BoolFlag{
+								Name:    "pretty",
+								Aliases: []string{"p"},
+								EnvVars: []string{"AA_RUN_PRETTY"},
+								Usage:   "pretty print and highlight json, xml, html, form, yaml and ndjson responses",
+							},
+							&cli.IntFlag{
+								Name:    "indent",
+								EnvVars: []string{"AA_RUN_INDENT"},
+								Value:   2,
+								Usage:   "number of spaces to indent pretty printed responses",
+							},
 							&cli.StringFlag{
 								Name:    "body",
 								Aliases: []string{"b"},
 								EnvVars: []string{"AA_RUN_BODY"},
 								Usage:   "save response body to given file instead of printing it",
 							},
+							&cli.BoolFlag{
+								Name:  "compressed",
+								Usage: "save the response body without decoding its content encoding (can't be used with --filter)",
+							},
+							&cli.BoolFlag{
+								Name:    "stream",
+								Aliases: []string{"s"},
+								Usage:   "print the response body as it arrives (always on for server-sent events)",
+							},
+							&cli.IntFlag{
+								Name:  "max-events",
+								Usage: "when streaming, stop after this many events (or lines)",
+							},
+							&cli.DurationFlag{
+								Name:  "timeout",
+								Usage: "stop the request after this long, keeping what was streamed so far",
+							},
+							&cli.StringFlag{
+								Name:    "filter",
+								Aliases: []string{"f"},
+								Usage:   "only show (or save) the parts of a json body matching this path (e.g. '.items[*].id')",
+							},
 							&cli.StringFlag{
 								Name:  "include",
 								Usage: "when pretty printing json, only include this comma-separated list of top level keys",
@@ -80,6 +127,89 @@ func main() {
 						Usage:  "run a list of requests",
 						Action: wrap(requestrun),
 					},
+					{
+						Name:      "export",
+						Aliases:   []string{"e"},
+						Usage:     "print requests as commands or code that can be run without aa",
+						ArgsUsage: "<name>...",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:    "format",
+								Aliases: []string{"F"},
+								Value:   ExportCurl,
+								Usage:   "what to export as: curl, httpie, raw (HTTP/1.1) or go",
+							},
+						},
+						Action: wrap(requestexport),
+					},
+					{
+						Name:    "import",
+						Aliases: []string{"i"},
+						Usage:   "add requests from other tools to the config",
+						Subcommands: []*cli.Command{
+							{
+								Name:      "curl",
+								Usage:     "import a curl command line (from the arguments or stdin)",
+								ArgsUsage: "['curl ...' | -- curl ...]",
+								Flags: append([]cli.Flag{
+									&cli.StringFlag{
+										Name:    "name",
+										Aliases: []string{"n"},
+										Usage:   "the name of the request (default: from the method and url)",
+									},
+								}, importFlags...),
+								Action: importcurl,
+							},
+							{
+								Name:      "postman",
+								Usage:     "import Postman (v2.0 or v2.1) collections and environments",
+								ArgsUsage: "<collection.json | environment.json>...",
+								Flags:     append([]cli.Flag{environmentFileFlag}, importFlags...),
+								Action:    importpostman,
+							},
+							{
+								Name:      "insomnia",
+								Usage:     "import the requests and environments of an Insomnia (v4) export",
+								ArgsUsage: "<export.json>",
+								Flags:     append([]cli.Flag{environmentFileFlag}, importFlags...),
+								Action:    importinsomnia,
+							},
+							{
+								Name:      "bruno",
+								Usage:     "import the requests and environments of a Bruno collection",
+								ArgsUsage: "<folder>",
+								Flags:     append([]cli.Flag{environmentFileFlag}, importFlags...),
+								Action:    importbruno,
+							},
+							{
+								Name:      "har",
+								Usage:     "import the requests of an HTTP Archive (e.g. from a browser)",
+								ArgsUsage: "<file.har>",
+								Flags: append([]cli.Flag{
+									&cli.StringFlag{
+										Name:    "match",
+										Aliases: []string{"m"},
+										Usage:   "only import requests with URLs matching the regular expression",
+									},
+								}, importFlags...),
+								Action: importhar,
+							},
+						},
+					},
+					{
+						Name:      "snapshot",
+						Aliases:   []string{"snap", "s"},
+						Usage:     "run requests and compare their responses to the approved snapshots",
+						ArgsUsage: "<name>...",
+						Flags: []cli.Flag{
+							&cli.BoolFlag{
+								Name:    "update",
+								Aliases: []string{"u"},
+								Usage:   "approve the responses as the new snapshots",
+							},
+						},
+						Action: wrap(requestsnapshot),
+					},
 					{
 						Name:    "list",
 						Aliases: []string{"l", "ls"},
@@ -88,6 +218,113 @@ func main() {
 					},
 				},
 			},
+			{
+				Name:  "openapi",
+				Usage: "work with OpenAPI 3 documents",
+				Subcommands: []*cli.Command{
+					{
+						Name:      "import",
+						Aliases:   []string{"i"},
+						Usage:     "add a request for each operation and environments for the servers",
+						ArgsUsage: "<spec.yaml>",
+						Flags:     append([]cli.Flag{environmentFileFlag}, importFlags...),
+						Action:    openapiimport,
+					},
+				},
+			},
+			{
+				Name:  "mock",
+				Usage: "serve the saved responses of requests",
+				Subcommands: []*cli.Command{
+					{
+						Name:  "serve",
+						Usage: "start an HTTP server that answers requests matching the URLs of requests with their saved responses",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:    "address",
+								Aliases: []string{"a"},
+								EnvVars: []string{"AA_MOCK_ADDRESS"},
+								Value:   "localhost:8080",
+								Usage:   "the address to listen on",
+							},
+							&cli.BoolFlag{
+								Name:  "latency",
+								Usage: "wait as long as the saved response took before answering",
+							},
+							&cli.BoolFlag{
+								Name:  "cors",
+								Usage: "allow requests from any origin (e.g. a frontend served elsewhere)",
+							},
+						},
+						Action: wrap(mockserve),
+					},
+				},
+			},
+			{
+				Name:  "state",
+				Usage: "manage the responses, raw requests and history that are saved",
+				Subcommands: []*cli.Command{
+					{
+						Name:   "migrate",
+						Usage:  "move files saved in the config folder to the state folder of the environment",
+						Action: statemigrate,
+					},
+				},
+			},
+			{
+				Name:    "history",
+				Aliases: []string{"hist", "h"},
+				Usage:   "browse and compare the responses of previous runs",
+				Subcommands: []*cli.Command{
+					{
+						Name:      "list",
+						Aliases:   []string{"l", "ls"},
+						Usage:     "list the saved responses of a request",
+						ArgsUsage: "<name>",
+						Action:    historylist,
+					},
+					{
+						Name:      "show",
+						Aliases:   []string{"s"},
+						Usage:     "show a saved response (the newest by default)",
+						ArgsUsage: "<name> [entry]",
+						Action:    historyshow,
+					},
+					{
+						Name:      "diff",
+						Aliases:   []string{"d"},
+						Usage:     "compare two saved responses (the newest two by default)",
+						ArgsUsage: "<name> [a] [b]",
+						Flags: []cli.Flag{
+							&cli.StringSliceFlag{
+								Name:  "ignore-header",
+								Value: cli.NewStringSlice("Date"),
+								Usage: "headers that aren't compared",
+							},
+						},
+						Action: historydiff,
+					},
+					{
+						Name:      "export",
+						Aliases:   []string{"e"},
+						Usage:     "export the newest run of requests as an HTTP Archive (HAR)",
+						ArgsUsage: "<name>...",
+						Flags: []cli.Flag{
+							&cli.BoolFlag{
+								Name:    "all",
+								Aliases: []string{"a"},
+								Usage:   "export every run in the history",
+							},
+							&cli.StringFlag{
+								Name:    "file",
+								Aliases: []string{"f"},
+								Usage:   "the file to write the archive to (default: stdout)",
+							},
+						},
+						Action: historyexport,
+					},
+				},
+			},
 		},
 	}
 	app.Run(os.Args)
@@ -96,16 +333,27 @@ func main() {
 func wrap(f func(ctx *cli.Context, cfg *Config, env Environment) error) cli.ActionFunc {
 	return func(c *cli.Context) error {
 		// Get our confign
-		cfg, err := NewConfig(c.String("config"))
+		cfg, err := NewConfig(c.String("config"), stateRoot(c))
 		if err != nil {
 			return cli.Exit(color.Red.Sprintf("loading config (%v): %v\n", c.String("config"), err), -1)
 		}
 
 		// Make sure we have an environment.
+		if c.String("environment") == "" {
+			return cli.Exit(color.Red.Sprintf("an environment is required (--environment or AA_ENVIRONMENT)\n"), -1)
+		}
 		env, ok := cfg.Environments[c.String("environment")]
 		if !ok {
 			return cli.Exit(color.Red.Sprintf("environment '%v' not found\n", c.String("environment")), -1)
 		}
+		if err := checkEnvironment(c.String("environment")); err != nil {
+			return cli.Exit(color.Red.Sprintf("%v\n", err), -1)
+		}
+
+		// Add the responses of previous runs.
+		if err := loadState(c, cfg); err != nil {
+			return cli.Exit(color.Red.Sprintf("loading responses (%v): %v\n", stateRoot(c), err), -1)
+		}
 
 		return f(c, cfg, env)
 	}
@@ -128,89 +376,264 @@ func requestrun(c *cli.Context, cfg *Config, env Environment) error {
 		return cli.Exit(color.Red.Sprintf("run expects at least one request name"), -1)
 	}
 
+	// Filters only work on the decoded body.
+	if c.String("filter") != "" && c.Bool("compressed") {
+		return cli.Exit(color.Red.Sprintf("--filter and --compressed can't be used together"), -1)
+	}
+
+	output := c.String("output")
+	if err := startOutput(output); err != nil {
+		return cli.Exit(color.Red.Sprintf("%v", err), -1)
+	}
+	defer color.ResetOutput()
+
 	// Run for each request.
+	failed := 0
 	for x := 0; x < c.Args().Len(); x++ {
+		result, err := execute(c, cfg, env, c.Args().Get(x))
+		if err != nil {
+			return err
+		}
+		if len(result.Violations) > 0 {
+			failed++
+		}
 
-		// Flatten the interpolation data.
-		vars := map[string]string{}
-		for k, v := range cfg.Responses {
-			v.Flatten(vars, k)
+		err = writeOutput(os.Stdout, output, result)
+		if err != nil {
+			return cli.Exit(color.Red.Sprintf("writing output: %v", err), -1)
 		}
-		env.Flatten(vars)
-
-		// Print out the name.
-		name := c.Args().Get(x)
-		color.Magenta.Println("================================================================")
-		color.Magenta.Println(name)
-		color.Magenta.Println("================================================================")
-		req, ok := cfg.Requests[name]
-		if !ok {
-			return cli.Exit(color.Red.Sprintf("request '%v' not found", name), -1)
+	}
+	if failed > 0 {
+		return cli.Exit(color.Red.Sprintf("%v of %v requests had violations", failed, c.Args().Len()), 1)
+	}
+	return nil
+}
+
+// flattenVars flattens the saved responses and the environment into
+// the values used for interpolation.
+func flattenVars(c *cli.Context, cfg *Config, env Environment) map[string]string {
+	vars := map[string]string{}
+	for k, v := range cfg.Responses {
+		v.Flatten(vars, k)
+		v.FlattenPrefix(vars, "responses@"+c.String("environment")+"."+k)
+	}
+	for e, responses := range cfg.EnvironmentResponses {
+		for k, v := range responses {
+			v.FlattenPrefix(vars, "responses@"+e+"."+k)
 		}
+	}
+	env.Flatten(vars)
+	return vars
+}
 
-		req.Interpolate(vars)
+// execute runs the named request and saves its response for later
+// requests and the history.
+func execute(c *cli.Context, cfg *Config, env Environment, name string) (RunResult, error) {
+	// Flatten the interpolation data.
+	vars := flattenVars(c, cfg, env)
+
+	// Print out the name.
+	color.Magenta.Println("================================================================")
+	color.Magenta.Println(name)
+	color.Magenta.Println("================================================================")
+	req, ok := cfg.Requests[name]
+	if !ok {
+		return RunResult{}, cli.Exit(color.Red.Sprintf("request '%v' not found", name), -1)
+	}
 
-		resp, err := run(c, name, req, cfg.Preferences)
-		if err != nil {
-			return cli.Exit(color.Red.Sprintf("running %v: %v", name, err), -1)
+	req.Interpolate(vars)
+
+	proxy, err := NewProxyConfig(vars)
+	if err != nil {
+		return RunResult{}, cli.Exit(color.Red.Sprintf("configuring proxy: %v", err), -1)
+	}
+
+	resolve, err := NewResolveMap(vars, cfg.Preferences)
+	if err != nil {
+		return RunResult{}, cli.Exit(color.Red.Sprintf("configuring resolve: %v", err), -1)
+	}
+
+	opts := TransportOptions{Proxy: proxy, Resolve: resolve}
+	var resp *Response
+	var violations []string
+	switch req.Type {
+	case "", RequestTypeHTTP:
+		resp, err = run(c, name, req, opts, cfg.Preferences)
+	case RequestTypeWebSocket:
+		resp, err = runWebSocket(c, name, req, opts, cfg.Preferences)
+		// The transcript of a failed exchange is still saved.
+		if err != nil && resp != nil {
+			color.Red.Printf("<%v>\n", err)
+			violations = append(violations, "websocket: "+err.Error())
+			err = nil
 		}
+	case RequestTypeGRPC:
+		resp, err = runGRPC(c, name, req, opts, cfg.Preferences)
+	default:
+		err = fmt.Errorf("unsupported request type '%v'", req.Type)
+	}
+	if err != nil {
+		return RunResult{}, cli.Exit(color.Red.Sprintf("running %v: %v", name, err), -1)
+	}
 
-		// Flatten for upcoming runs.
-		cfg.Responses[name] = *resp
+	result := RunResult{
+		Name:        name,
+		Environment: c.String("environment"),
+		Request:     redactRequest(req),
+		Response:    resp,
+		Violations:  violations,
+	}
 
-		// Also save to disk for future executions.
-		y, err := yaml.Marshal(&Config{
-			Responses: map[string]Response{
-				name: *resp,
-			},
-		})
+	// Check the response against the API's OpenAPI document.
+	if cfg.Preferences["openapi"] != "" && (req.Type == "" || req.Type == RequestTypeHTTP) {
+		result.Violations, err = checkOpenAPI(c, cfg, req, resp)
 		if err != nil {
-			return cli.Exit(color.Red.Sprintf("marshalling response yaml: %v", err), -1)
+			return RunResult{}, cli.Exit(color.Red.Sprintf("checking %v against the OpenAPI document: %v", name, err), -1)
 		}
-		err = ioutil.WriteFile(filepath.Join(c.String("config"), name+"-response.yaml"), y, 0660)
+	}
+	if req.Schema != "" {
+		violations, err := checkSchema(c, req, resp)
 		if err != nil {
-			return cli.Exit(color.Red.Sprintf("saving response yaml: %v", err), -1)
+			return RunResult{}, cli.Exit(color.Red.Sprintf("checking %v against its schema: %v", name, err), -1)
 		}
+		result.Violations = append(result.Violations, violations...)
 	}
-	return nil
+
+	// Flatten for upcoming runs.
+	cfg.Responses[name] = *resp
+
+	// Also save to disk for future executions.
+	y, err := yaml.Marshal(&Config{
+		Responses: map[string]Response{
+			name: *resp,
+		},
+	})
+	if err != nil {
+		return RunResult{}, cli.Exit(color.Red.Sprintf("marshalling response yaml: %v", err), -1)
+	}
+	path, err := statePath(c, name, "-response.yaml")
+	if err != nil {
+		return RunResult{}, cli.Exit(color.Red.Sprintf("saving response yaml: %v", err), -1)
+	}
+	err = ioutil.WriteFile(path, y, 0660)
+	if err != nil {
+		return RunResult{}, cli.Exit(color.Red.Sprintf("saving response yaml: %v", err), -1)
+	}
+
+	// Keep every response so we can see how they change.
+	if err := saveHistory(stateRoot(c), result); err != nil {
+		return RunResult{}, cli.Exit(color.Red.Sprintf("saving history: %v", err), -1)
+	}
+	return result, nil
 }
 
-func run(ctx *cli.Context, name string, r Request, prefs map[string]string) (*Response, error) {
-	in, err := os.Create(filepath.Join(ctx.String("config"), name+"-response.raw"))
+// createRawFiles creates the files the raw response and request are
+// logged to.
+func createRawFiles(ctx *cli.Context, name string) (in, out *os.File, err error) {
+	inPath, err := statePath(ctx, name, "-response.raw")
 	if err != nil {
-		return nil, fmt.Errorf("creating response raw file: %v", err)
+		return nil, nil, err
 	}
-	defer in.Close()
-	out, err := os.Create(filepath.Join(ctx.String("config"), name+"-request.raw"))
+	outPath, err := statePath(ctx, name, "-request.raw")
 	if err != nil {
-		return nil, fmt.Errorf("creating request raw file: %v", err)
+		return nil, nil, err
 	}
-	defer out.Close()
 
-	// Create our client
-	tlsCfg := &tls.Config{}
-	if ignore, ok := prefs["ignore-certs"]; ok && ignore == "true" {
-		tlsCfg.InsecureSkipVerify = true
+	in, err = os.Create(inPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating response raw file: %v", err)
 	}
+	out, err = os.Create(outPath)
+	if err != nil {
+		in.Close()
+		return nil, nil, fmt.Errorf("creating request raw file: %v", err)
+	}
+	return in, out, nil
+}
 
-	client := http.Client{}
-	client.Transport = NewHelperTransport(in, out, tlsCfg)
+func newTLSConfig(prefs map[string]string, opts TLSOptions) (*tls.Config, error) {
+	cfg := &tls.Config{}
+	if ignore, ok := prefs["ignore-certs"]; (ok && ignore == "true") || opts.Insecure {
+		cfg.InsecureSkipVerify = true
+	}
 
-	req := &http.Request{
-		Method: r.Method,
+	if opts.Cert != "" {
+		key := opts.Key
+		if key == "" {
+			key = opts.Cert
+		}
+		cert, err := tls.LoadX509KeyPair(opts.Cert, key)
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate: %v", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	if opts.CA != "" {
+		buf, err := ioutil.ReadFile(opts.CA)
+		if err != nil {
+			return nil, fmt.Errorf("reading ca: %v", err)
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(buf) {
+			return nil, fmt.Errorf("no certificates found in ca '%v'", opts.CA)
+		}
 	}
+	return cfg, nil
+}
 
-	// Setup the URL
+// addAuthentication adds the headers for the authentication of a
+// request.
+func addAuthentication(h http.Header, auth map[string]string) {
+	if authType, ok := auth["type"]; ok {
+		switch strings.ToLower(authType) {
+		case "bearer":
+			h.Add("Authorization", "Bearer "+auth["token"])
+			color.Blue.Printf("%v: %v\n", "Authorization", "Bearer "+auth["token"])
+		case "basic":
+			v := "Basic " + base64.StdEncoding.EncodeToString([]byte(auth["username"]+":"+auth["password"]))
+			h.Add("Authorization", v)
+			color.Blue.Printf("%v: %v\n", "Authorization", v)
+		}
+	}
+}
+
+// requestURL is the URL of the request with its query added. If the
+// URL is for a unix socket, the path of the socket is returned and the
+// URL is changed to be an HTTP URL.
+func requestURL(r Request) (*url.URL, string, error) {
 	u, err := url.Parse(r.URL)
 	if err != nil {
-		return nil, fmt.Errorf("parsing url: %v", err)
+		return nil, "", fmt.Errorf("parsing url: %v", err)
+	}
+	socket := ""
+	if u.Scheme == "unix" {
+		socket = unixSocketURL(u)
 	}
 	q := u.Query()
 	for k, v := range r.Query {
 		q.Add(k, v)
 	}
 	u.RawQuery = q.Encode()
-	req.URL = u
+	return u, socket, nil
+}
+
+// newHTTPRequest creates the HTTP request of r, printing it as it
+// goes. The socket is set if the request is to a unix socket.
+func newHTTPRequest(r Request) (*http.Request, string, error) {
+	u, socket, err := requestURL(r)
+	if err != nil {
+		return nil, "", err
+	}
+	if socket != "" {
+		color.Blue.Printf("<unix socket '%v'>\n", socket)
+	}
+
+	req := &http.Request{
+		Method: r.Method,
+		URL:    u,
+		Host:   u.Host,
+	}
 	color.Blue.Printf("%v %v\n", req.Method, req.URL)
 
 	// Create Headers
@@ -220,25 +643,66 @@ func run(ctx *cli.Context, name string, r Request, prefs map[string]string) (*Re
 		color.Blue.Printf("%v: %v\n", k, v)
 	}
 
-	// Setup Authentication
-	if authType, ok := r.Authentication["type"]; ok {
-		switch strings.ToLower(authType) {
-		case "bearer":
-			req.Header.Add("Authorization", "Bearer "+r.Authentication["token"])
-			color.Blue.Printf("%v: %v\n", "Authorization", "Bearer "+r.Authentication["token"])
+	// We decode the body ourselves so we can report on compression.
+	if req.Header.Get("Accept-Encoding") == "" {
+		ae := r.AcceptEncoding
+		if ae == "" {
+			ae = DefaultAcceptEncoding
 		}
+		req.Header.Set("Accept-Encoding", ae)
+		color.Blue.Printf("%v: %v\n", "Accept-Encoding", ae)
 	}
 
+	// Setup Authentication
+	addAuthentication(req.Header, r.Authentication)
+
 	// Setup the body
 	body, err := createRequestBody(req, r.Body)
 	if err != nil {
-		return nil, fmt.Errorf("creating request body: %v", err)
+		return nil, "", fmt.Errorf("creating request body: %v", err)
 	}
 	req.Body = body
 	color.Blue.Printf("\n")
+	return req, socket, nil
+}
+
+func run(ctx *cli.Context, name string, r Request, opts TransportOptions, prefs map[string]string) (*Response, error) {
+	in, out, err := createRawFiles(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+	defer out.Close()
+
+	// Create our client
+	opts.TLS, err = newTLSConfig(prefs, r.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	req, socket, err := newHTTPRequest(r)
+	if err != nil {
+		return nil, err
+	}
 
+	opts.Socket = socket
+	opts.Protocol = r.Protocol
+	transport, err := NewHelperTransport(in, out, opts)
+	if err != nil {
+		return nil, err
+	}
+	client := http.Client{Transport: transport}
+
+	// Stop the request if it takes too long.
+	reqCtx, cancel := context.WithCancel(context.Background())
+	if t := ctx.Duration("timeout"); t > 0 {
+		reqCtx, cancel = context.WithTimeout(reqCtx, t)
+	}
+	defer cancel()
 	// Do request
 	start := time.Now()
+	timings := Timings{}
+	req = req.WithContext(httptrace.WithClientTrace(reqCtx, newTimingTrace(&timings, start)))
 	resp, err := client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("making request: %v", err)
@@ -250,73 +714,100 @@ func run(ctx *cli.Context, name string, r Request, prefs map[string]string) (*Re
 	}
 	color.Green.Printf("\n")
 
-	// Copy response body
-	ww := []io.Writer{}
-	b := &bytes.Buffer{}
-	ww = append(ww, b)
-
-	// Add a file writer.
-	if f := ctx.String("body"); f != "" {
-		b, err := os.Create(f)
+	raw := &bytes.Buffer{}
+	b := raw
+	encoding := resp.Header.Get("Content-Encoding")
+	stream := ctx.Bool("stream") || isEventStream(resp)
+	var events []Event
+	if stream {
+		// Print the body as it arrives, keeping what came over the
+		// wire.
+		dec, err := decodeBody(io.TeeReader(resp.Body, raw), encoding)
 		if err != nil {
-			return nil, fmt.Errorf("opening body file '%v': %v", f, err)
+			return nil, fmt.Errorf("decoding body (%v): %v", encoding, err)
+		}
+		b = &bytes.Buffer{}
+		events, err = streamBody(dec, b, isEventStream(resp), ctx.Int("max-events"))
+		resp.Body.Close()
+		if err != nil && reqCtx.Err() == nil {
+			return nil, fmt.Errorf("streaming body: %v", err)
+		}
+	} else {
+		// Copy response body as it came over the wire.
+		_, err = io.Copy(raw, resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("reading body: %v", err)
+		}
+		resp.Body.Close()
+
+		// Decode the content encoding.
+		if dec, err := decodeBody(bytes.NewReader(raw.Bytes()), encoding); err != nil {
+			color.Red.Printf("<not decoding body: %v>\n", err)
+		} else {
+			b = &bytes.Buffer{}
+			_, err = io.Copy(b, dec)
+			dec.Close()
+			if err != nil {
+				return nil, fmt.Errorf("decoding body (%v): %v", encoding, err)
+			}
 		}
-		defer b.Close()
-		ww = append(ww, b)
-		color.Green.Printf("<body saved to '%v'>\n", f)
 	}
 
-	_, err = io.Copy(io.MultiWriter(ww...), resp.Body)
+	display, err := formatBody(ctx, resp.Header.Get("Content-Type"), b.Bytes())
 	if err != nil {
-		return nil, fmt.Errorf("reading body: %v", err)
-	}
-	resp.Body.Close()
-
-	if ctx.Bool("json") && strings.Contains(resp.Header.Get("Content-Type"), "application/json") {
-		tmp := map[string]interface{}{}
-		if err := json.Unmarshal(b.Bytes(), &tmp); err == nil {
-			if include := ctx.String("include"); include != "" {
-				parts := strings.Split(include, ",")
-				n := map[string]interface{}{}
-				for _, part := range parts {
-					if v, ok := tmp[part]; ok {
-						n[part] = v
-					}
-				}
-				tmp = n
-			} else if exclude := ctx.String("exclude"); exclude != "" {
-				parts := strings.Split(exclude, ",")
-				for _, part := range parts {
-					delete(tmp, part)
-				}
-			}
+		color.Red.Printf("<%v>\n", err)
+	}
 
-			buf, err := json.MarshalIndent(tmp, "", "     ")
-			if err == nil {
-				b = bytes.NewBuffer(buf)
-			}
+	// Save to a file.
+	if f := ctx.String("body"); f != "" {
+		data := savedBody(ctx.String("filter") != "", ctx.Bool("compressed"), raw.Bytes(), b.Bytes(), display)
+		if err := ioutil.WriteFile(f, data, 0666); err != nil {
+			return nil, fmt.Errorf("writing body file '%v': %v", f, err)
 		}
+		color.Green.Printf("<body saved to '%v'>\n", f)
 	}
 
-	if ctx.String("body") == "" {
-		color.Green.Printf("%v\n", b.String())
+	// Create and return response information.
+	response := &Response{
+		Size:    int64(b.Len()),
+		Events:  events,
+		display: string(display),
+	}
+	if encoding != "" {
+		response.Encoding = encoding
+		response.CompressedSize = int64(raw.Len())
+	}
+
+	if ctx.String("body") == "" && !stream {
+		printBody(ctx, resp.Header.Get("Content-Type"), b.Bytes(), display)
 	}
 
 	duration := time.Since(start)
+	timings.Total = duration
 	color.Magenta.Printf("\nduration: %v\n", duration)
+	if stream {
+		color.Magenta.Printf("events: %v\n", len(events))
+	}
+	if response.Encoding != "" {
+		color.Magenta.Printf("size: %v bytes (%v bytes %v)\n", response.Size, response.CompressedSize, response.Encoding)
+	} else {
+		color.Magenta.Printf("size: %v bytes\n", response.Size)
+	}
 
-	// Create and return response information.
-	response := &Response{
-		When:       time.Now(),
-		Status:     resp.Status,
-		StatusCode: resp.StatusCode,
-		Duration:   duration,
-		Body:       b.String(),
+	response.When = time.Now()
+	response.Status = resp.Status
+	response.StatusCode = resp.StatusCode
+	response.Duration = duration
+	response.Timings = timings
+	response.Protocol = resp.Proto
+	response.Body = b.String()
+	if resp.TLS != nil {
+		response.ALPN = resp.TLS.NegotiatedProtocol
 	}
 
 	response.Headers = map[string]string{}
 	for k, v := range resp.Header {
-		response.Headers[k] = fmt.Sprintf("%s", v)
+		response.setHeader(k, v)
 	}
 
 	return response, nil
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gookit/color"
+	"github.com/urfave/cli/v2"
+)
+
+// mockRoute is a saved response and the requests it is served for.
+type mockRoute struct {
+	Name     string
+	Method   string
+	Template string
+	Path     *regexp.Regexp
+	Query    url.Values
+	Response Response
+
+	// literal is the number of characters of the path that aren't
+	// variables. Routes with more of them are more specific.
+	literal int
+}
+
+// mockHopHeaders are the headers of saved responses that aren't sent.
+// Bodies are saved decoded, so their length and encoding change, and
+// the date is the time the response is served.
+var mockHopHeaders = map[string]bool{
+	"Date":              true,
+	"Content-Length":    true,
+	"Content-Encoding":  true,
+	"Transfer-Encoding": true,
+	"Connection":        true,
+}
+
+// mockVariable replaces the variables that are left after
+// interpolation so they aren't changed when parsing the URL.
+const mockVariable = "\x00"
+
+// newMockRoute makes the route for the request. The interpolated URL
+// has to match the path of incoming requests and variables that don't
+// have a value match any segment of it.
+func newMockRoute(name string, r Request, resp Response) mockRoute {
+	u := re.ReplaceAllString(r.URL, mockVariable)
+
+	// The scheme and host (or the variable of the base URL) aren't
+	// part of the path.
+	if i := strings.Index(u, "://"); i >= 0 {
+		u = u[i+3:]
+		if j := strings.Index(u, "/"); j >= 0 {
+			u = u[j:]
+		} else {
+			u = "/"
+		}
+	} else if !strings.HasPrefix(u, "/") {
+		if j := strings.Index(u, "/"); j >= 0 {
+			u = u[j:]
+		} else {
+			u = "/"
+		}
+	}
+
+	query := url.Values{}
+	if i := strings.IndexAny(u, "?#"); i >= 0 {
+		if u[i] == '?' {
+			raw := strings.SplitN(u[i+1:], "#", 2)[0]
+			query, _ = url.ParseQuery(raw)
+		}
+		u = u[:i]
+	}
+	for k, v := range r.Query {
+		query.Set(k, v)
+	}
+
+	path := strings.TrimSuffix(u, "/")
+	pattern := &strings.Builder{}
+	literal := 0
+	for x, part := range strings.Split(path, mockVariable) {
+		if x > 0 {
+			pattern.WriteString(`[^/]+`)
+		}
+		pattern.WriteString(regexp.QuoteMeta(part))
+		literal += len(part)
+	}
+
+	template := strings.ReplaceAll(path, mockVariable, "*")
+	if template == "" {
+		template = "/"
+	}
+	method := strings.ToUpper(r.Method)
+	if method == "" {
+		method = http.MethodGet
+	}
+	return mockRoute{
+		Name:     name,
+		Method:   method,
+		Template: template,
+		Path:     regexp.MustCompile("^" + pattern.String() + "/?$"),
+		Query:    query,
+		Response: resp,
+		literal:  literal,
+	}
+}
+
+// matches is how well the route matches the request with the method
+// and whether it does. Query parameters with the same values count
+// more than the literal characters of the path and ones with other
+// values count against it, so a route without them wins.
+func (m mockRoute) matches(method string, r *http.Request) (int, bool) {
+	if m.Method != method || !m.Path.MatchString(r.URL.Path) {
+		return 0, false
+	}
+	score := m.literal
+	query := r.URL.Query()
+	for k, v := range m.Query {
+		if len(v) > 0 && query.Get(k) == v[0] {
+			score += 1000
+		} else {
+			score--
+		}
+	}
+	return score, true
+}
+
+// MockServer serves the saved responses of the requests.
+type MockServer struct {
+	Routes  []mockRoute
+	Latency bool
+	CORS    bool
+}
+
+// NewMockServer makes the routes of the requests that have a saved
+// response. The requests are interpolated with vars first.
+func NewMockServer(cfg *Config, vars map[string]string) *MockServer {
+	m := &MockServer{}
+	for name, r := range cfg.Requests {
+		resp, ok := cfg.Responses[name]
+		if !ok || (r.Type != "" && r.Type != RequestTypeHTTP) {
+			continue
+		}
+		r.Interpolate(vars)
+		m.Routes = append(m.Routes, newMockRoute(name, r, resp))
+	}
+	sort.Slice(m.Routes, func(x, y int) bool {
+		if m.Routes[x].Template != m.Routes[y].Template {
+			return m.Routes[x].Template < m.Routes[y].Template
+		}
+		return m.Routes[x].Method < m.Routes[y].Method
+	})
+	return m
+}
+
+// Find is the most specific route for the request. HEAD requests use
+// the GET routes when there isn't a HEAD route for them.
+func (m *MockServer) Find(r *http.Request) (mockRoute, bool) {
+	route, ok := m.find(r.Method, r)
+	if !ok && r.Method == http.MethodHead {
+		return m.find(http.MethodGet, r)
+	}
+	return route, ok
+}
+
+func (m *MockServer) find(method string, r *http.Request) (mockRoute, bool) {
+	best, found, ok := 0, mockRoute{}, false
+	for _, route := range m.Routes {
+		if score, matches := route.matches(method, r); matches && (!ok || score > best) {
+			best, found, ok = score, route, true
+		}
+	}
+	return found, ok
+}
+
+func (m *MockServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if m.CORS {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.Header().Set("Access-Control-Allow-Methods", r.Header.Get("Access-Control-Request-Method"))
+			if h := r.Header.Get("Access-Control-Request-Headers"); h != "" {
+				w.Header().Set("Access-Control-Allow-Headers", h)
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+
+	route, ok := m.Find(r)
+	if !ok {
+		color.Yellow.Printf("%v %v -> no saved response\n", r.Method, r.URL.RequestURI())
+		http.Error(w, fmt.Sprintf("no saved response for %v %v", r.Method, r.URL.Path), http.StatusNotFound)
+		return
+	}
+
+	resp := route.Response
+	if m.Latency {
+		time.Sleep(resp.Duration)
+	}
+	for k := range resp.Headers {
+		if mockHopHeaders[http.CanonicalHeaderKey(k)] {
+			continue
+		}
+		if m.CORS && strings.HasPrefix(http.CanonicalHeaderKey(k), "Access-Control-") {
+			continue
+		}
+		for _, v := range resp.header(k) {
+			w.Header().Add(k, v)
+		}
+	}
+	status := resp.StatusCode
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.WriteHeader(status)
+	if r.Method != http.MethodHead {
+		w.Write([]byte(resp.Body))
+	}
+	color.Green.Printf("%v %v -> %v (%v)\n", r.Method, r.URL.RequestURI(), route.Name, status)
+}
+
+func mockserve(c *cli.Context, cfg *Config, env Environment) error {
+	m := NewMockServer(cfg, flattenVars(c, cfg, env))
+	m.Latency, m.CORS = c.Bool("latency"), c.Bool("cors")
+	if len(m.Routes) == 0 {
+		return cli.Exit(color.Red.Sprintf("no requests have saved responses in the %v environment", c.String("environment")), -1)
+	}
+	for _, route := range m.Routes {
+		color.Magenta.Printf("%v %v -> %v\n", route.Method, route.Template, route.Name)
+	}
+
+	color.Magenta.Printf("serving %v saved responses on %v\n", len(m.Routes), c.String("address"))
+	if err := http.ListenAndServe(c.String("address"), m); err != nil {
+		return cli.Exit(color.Red.Sprintf("serving: %v", err), -1)
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMockServer(t *testing.T) {
+	resp := func(body string, headers map[string]string) Response {
+		return Response{StatusCode: http.StatusOK, Body: body, Headers: headers}
+	}
+	cfg := &Config{
+		Requests: map[string]Request{
+			"users":     {Method: "GET", URL: "{{base}}/users"},
+			"user":      {Method: "GET", URL: "{{base}}/users/{{id}}"},
+			"me":        {Method: "GET", URL: "{{base}}/users/me"},
+			"active":    {Method: "GET", URL: "{{base}}/users?state=active"},
+			"create":    {Method: "POST", URL: "{{base}}/users"},
+			"login":     {Method: "POST", URL: "{{base}}/login"},
+			"unsaved":   {Method: "GET", URL: "{{base}}/unsaved"},
+			"websocket": {Type: RequestTypeWebSocket, URL: "ws://localhost/users"},
+		},
+		Responses: map[string]Response{
+			"users":  resp("users", map[string]string{"Content-Length": "[5]"}),
+			"user":   resp("user", nil),
+			"me":     resp("me", nil),
+			"active": resp("active", nil),
+			"create": {StatusCode: http.StatusCreated, Body: "created"},
+			"login": {
+				StatusCode:      http.StatusOK,
+				Body:            "login",
+				Headers:         map[string]string{"Set-Cookie": "[a=1 b=2]", "Access-Control-Allow-Origin": "[example.com]"},
+				RepeatedHeaders: map[string][]string{"Set-Cookie": {"a=1", "b=2"}},
+			},
+			"websocket": resp("websocket", nil),
+		},
+	}
+	m := NewMockServer(cfg, map[string]string{"base": "http://localhost:8080"})
+	if len(m.Routes) != 6 {
+		t.Fatalf("got %v routes, want 6", len(m.Routes))
+	}
+	s := httptest.NewServer(m)
+	defer s.Close()
+	cors := httptest.NewServer(&MockServer{Routes: m.Routes, CORS: true})
+	defer cors.Close()
+
+	tests := []struct {
+		name    string
+		server  *httptest.Server
+		method  string
+		path    string
+		headers map[string]string
+		status  int
+		body    string
+		want    map[string][]string
+	}{
+		{"route", s, "GET", "/users", nil, 200, "users", nil},
+		{"trailing slash", s, "GET", "/users/", nil, 200, "users", nil},
+		{"variable", s, "GET", "/users/42", nil, 200, "user", nil},
+		{"literal over variable", s, "GET", "/users/me", nil, 200, "me", nil},
+		{"query over path", s, "GET", "/users?state=active", nil, 200, "active", nil},
+		{"other query", s, "GET", "/users?state=inactive", nil, 200, "users", nil},
+		{"method", s, "POST", "/users", nil, 201, "created", nil},
+		{"no method", s, "DELETE", "/users", nil, 404, "no saved response for DELETE /users\n", nil},
+		{"no route", s, "GET", "/users/42/posts", nil, 404, "no saved response for GET /users/42/posts\n", nil},
+		{"unsaved", s, "GET", "/unsaved", nil, 404, "no saved response for GET /unsaved\n", nil},
+		{"head", s, "HEAD", "/users/42", nil, 200, "", nil},
+		{"hop headers", s, "GET", "/users", nil, 200, "users", map[string][]string{"Content-Length": {"5"}}},
+		{"repeated headers", s, "POST", "/login", nil, 200, "login", map[string][]string{
+			"Set-Cookie":                  {"a=1", "b=2"},
+			"Access-Control-Allow-Origin": {"example.com"},
+		}},
+		{"cors", cors, "POST", "/login", nil, 200, "login", map[string][]string{
+			"Access-Control-Allow-Origin": {"*"},
+		}},
+		{"cors preflight", cors, "OPTIONS", "/login", map[string]string{
+			"Origin":                         "http://example.com",
+			"Access-Control-Request-Method":  "POST",
+			"Access-Control-Request-Headers": "Content-Type",
+		}, 204, "", map[string][]string{
+			"Access-Control-Allow-Origin":  {"*"},
+			"Access-Control-Allow-Methods": {"POST"},
+			"Access-Control-Allow-Headers": {"Content-Type"},
+		}},
+		{"options without cors", s, "OPTIONS", "/login", map[string]string{
+			"Access-Control-Request-Method": "POST",
+		}, 404, "no saved response for OPTIONS /login\n", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, tt.server.URL+tt.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			body, err := ioutil.ReadAll(res.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != tt.status {
+				t.Errorf("got status %v, want %v", res.StatusCode, tt.status)
+			}
+			if string(body) != tt.body {
+				t.Errorf("got body %q, want %q", body, tt.body)
+			}
+			for k, v := range tt.want {
+				if got := res.Header.Values(k); !reflect.DeepEqual(got, v) {
+					t.Errorf("got %v %v, want %v", k, got, v)
+				}
+			}
+		})
+	}
+}
+
+func TestNewMockRoute(t *testing.T) {
+	tests := []struct {
+		name     string
+		req      Request
+		template string
+		literal  int
+	}{
+		{"base variable", Request{URL: "{{base}}/users/{{id}}"}, "/users/*", 7},
+		{"host", Request{URL: "https://example.com/users"}, "/users", 6},
+		{"root", Request{URL: "https://example.com"}, "/", 0},
+		{"query", Request{URL: "{{base}}/users?state=active#top"}, "/users", 6},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := newMockRoute(tt.name, tt.req, Response{})
+			if got.Method != http.MethodGet {
+				t.Errorf("got method %v, want GET", got.Method)
+			}
+			if got.Template != tt.template || got.literal != tt.literal {
+				t.Errorf("got %v (%v), want %v (%v)", got.Template, got.literal, tt.template, tt.literal)
+			}
+			if strings.Contains(tt.req.URL, "?") && got.Query.Get("state") != "active" {
+				t.Errorf("got query %v", got.Query)
+			}
+		})
+	}
+}
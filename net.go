@@ -1,45 +1,365 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"crypto/tls"
+	"encoding/base64"
+	"fmt"
 	"io"
 	"net"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
+
+	"golang.org/x/net/http2"
+	xproxy "golang.org/x/net/proxy"
 )
 
-func NewHelperTransport(in, out io.Writer, cfg *tls.Config) *http.Transport {
-	return &http.Transport{
-		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
-			c, err := (&net.Dialer{
-				Timeout:   30 * time.Second,
-				KeepAlive: 30 * time.Second,
-				DualStack: true,
-			}).DialContext(ctx, network, addr)
+// The protocols that can be selected for a request.
+const (
+	ProtocolHTTP1 = "http1.1"
+	ProtocolH2    = "h2"
+	ProtocolH2C   = "h2c"
+)
 
-			return &LoggerConn{conn: c, in: in, out: out}, err
-		},
-		DialTLSContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
-			c, err := (&tls.Dialer{
-				NetDialer: &net.Dialer{
-					Timeout:   30 * time.Second,
-					KeepAlive: 30 * time.Second,
-					DualStack: true,
-				},
-				Config: cfg,
-			}).DialContext(ctx, network, addr)
-
-			return &LoggerConn{conn: c, in: in, out: out}, err
+// TransportOptions changes how the helper transport connects.
+type TransportOptions struct {
+	TLS   *tls.Config
+	Proxy *ProxyConfig
+
+	// Socket is the path to a unix socket. If set, all connections
+	// are made to it instead of the address of the request.
+	Socket string
+
+	// Resolve maps host:port or host to the address that should be
+	// dialed instead (see NewResolveMap).
+	Resolve map[string]string
+
+	// Protocol is the HTTP protocol to speak. HTTP/1.1 is used if
+	// it's empty. h2 negotiates HTTP/2 over TLS and h2c uses
+	// cleartext HTTP/2 with prior knowledge. Proxies are only
+	// supported with HTTP/1.1; requests that would use one fail with
+	// h2 and h2c.
+	Protocol string
+}
+
+// HelperDialer makes the connections for the helper transport. It
+// handles unix sockets and resolve overrides and logs everything
+// read and written to in and out.
+type HelperDialer struct {
+	in     io.Writer
+	out    io.Writer
+	opts   TransportOptions
+	dialer *net.Dialer
+}
+
+func NewHelperDialer(in, out io.Writer, opts TransportOptions) *HelperDialer {
+	return &HelperDialer{
+		in:   in,
+		out:  out,
+		opts: opts,
+		dialer: &net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+			DualStack: true,
 		},
+	}
+}
+
+// DialContext makes a plain connection to addr.
+func (d *HelperDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	if d.opts.Socket != "" {
+		network, addr = "unix", d.opts.Socket
+	} else {
+		addr = resolveAddr(d.opts.Resolve, addr)
+	}
+	c, err := d.dialer.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LoggerConn{conn: c, in: d.in, out: d.out}, nil
+}
 
-		ForceAttemptHTTP2:     true,
-		MaxIdleConns:          100,
-		IdleConnTimeout:       90 * time.Second,
-		TLSHandshakeTimeout:   10 * time.Second,
-		ExpectContinueTimeout: 1 * time.Second,
-		Proxy:                 http.ProxyFromEnvironment,
+// DialTLSContext makes a TLS connection to addr using the given
+// config.
+func (d *HelperDialer) DialTLSContext(ctx context.Context, network, addr string, cfg *tls.Config) (net.Conn, error) {
+	// Keep the original host for SNI if we dial somewhere else.
+	to := resolveAddr(d.opts.Resolve, addr)
+	if d.opts.Socket != "" {
+		network, to = "unix", d.opts.Socket
+	}
+	if to != addr {
+		if cfg.ServerName == "" {
+			cfg = cfg.Clone()
+			cfg.ServerName, _, _ = net.SplitHostPort(addr)
+		}
+		addr = to
 	}
+
+	c, err := (&tls.Dialer{
+		NetDialer: d.dialer,
+		Config:    cfg,
+	}).DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LoggerConn{conn: c, in: d.in, out: d.out}, nil
+}
+
+// DialProxyContext connects to addr through the proxy, which is an
+// HTTP proxy (with CONNECT) or a SOCKS5 one. TLS is done over the
+// tunnel if cfg isn't nil. Only the traffic in the tunnel is logged.
+func (d *HelperDialer) DialProxyContext(ctx context.Context, proxy *url.URL, addr string, cfg *tls.Config) (net.Conn, error) {
+	var c net.Conn
+	var err error
+	switch proxy.Scheme {
+	case "socks5", "socks5h":
+		c, err = d.dialSOCKS5(ctx, proxy, addr)
+	case "http", "https":
+		c, err = d.dialConnect(ctx, proxy, addr)
+	default:
+		err = fmt.Errorf("unsupported scheme '%v'", proxy.Scheme)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("connecting through proxy %v: %v", proxy.Redacted(), err)
+	}
+
+	if cfg != nil {
+		if cfg.ServerName == "" {
+			cfg = cfg.Clone()
+			cfg.ServerName, _, _ = net.SplitHostPort(addr)
+		}
+		tc := tls.Client(c, cfg)
+		if err := tc.HandshakeContext(ctx); err != nil {
+			c.Close()
+			return nil, err
+		}
+		c = tc
+	}
+	return &LoggerConn{conn: c, in: d.in, out: d.out}, nil
+}
+
+// dialProxy connects to the proxy itself. HTTPS proxies are connected
+// to with TLS.
+func (d *HelperDialer) dialProxy(ctx context.Context, proxy *url.URL, port string) (net.Conn, error) {
+	addr := proxy.Host
+	if proxy.Port() == "" {
+		addr = net.JoinHostPort(proxy.Hostname(), port)
+	}
+	c, err := d.dialer.DialContext(ctx, "tcp", resolveAddr(d.opts.Resolve, addr))
+	if err != nil || proxy.Scheme != "https" {
+		return c, err
+	}
+	cfg := &tls.Config{ServerName: proxy.Hostname()}
+	if d.opts.TLS != nil {
+		cfg.InsecureSkipVerify = d.opts.TLS.InsecureSkipVerify
+	}
+	tc := tls.Client(c, cfg)
+	if err := tc.HandshakeContext(ctx); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return tc, nil
+}
+
+// dialConnect opens a tunnel to addr with an HTTP CONNECT request.
+func (d *HelperDialer) dialConnect(ctx context.Context, proxy *url.URL, addr string) (net.Conn, error) {
+	port := "80"
+	if proxy.Scheme == "https" {
+		port = "443"
+	}
+	c, err := d.dialProxy(ctx, proxy, port)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		c.SetDeadline(deadline)
+		defer c.SetDeadline(time.Time{})
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: http.Header{},
+	}
+	if u := proxy.User; u != nil {
+		password, _ := u.Password()
+		req.Header.Set("Proxy-Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(u.Username()+":"+password)))
+	}
+	if err := req.Write(c); err != nil {
+		c.Close()
+		return nil, err
+	}
+	br := bufio.NewReader(c)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		c.Close()
+		return nil, fmt.Errorf("CONNECT %v: %v", addr, resp.Status)
+	}
+	if br.Buffered() > 0 {
+		return &bufferedConn{Conn: c, r: br}, nil
+	}
+	return c, nil
+}
+
+// dialSOCKS5 opens a tunnel to addr with a SOCKS5 proxy.
+func (d *HelperDialer) dialSOCKS5(ctx context.Context, proxy *url.URL, addr string) (net.Conn, error) {
+	var auth *xproxy.Auth
+	if u := proxy.User; u != nil {
+		password, _ := u.Password()
+		auth = &xproxy.Auth{User: u.Username(), Password: password}
+	}
+	host := proxy.Host
+	if proxy.Port() == "" {
+		host = net.JoinHostPort(proxy.Hostname(), "1080")
+	}
+	dialer, err := xproxy.SOCKS5("tcp", resolveAddr(d.opts.Resolve, host), auth, d.dialer)
+	if err != nil {
+		return nil, err
+	}
+	return dialer.(xproxy.ContextDialer).DialContext(ctx, "tcp", addr)
+}
+
+// bufferedConn reads what was buffered while reading the response of
+// the proxy before reading from the connection.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (b *bufferedConn) Read(p []byte) (int, error) {
+	return b.r.Read(p)
+}
+
+func NewHelperTransport(in, out io.Writer, opts TransportOptions) (http.RoundTripper, error) {
+	d := NewHelperDialer(in, out, opts)
+
+	// The HTTP/2 transport can't use proxies.
+	h2 := func(t *http2.Transport) http.RoundTripper {
+		if opts.Proxy == nil || opts.Socket != "" {
+			return t
+		}
+		return &noProxyTransport{RoundTripper: t, protocol: opts.Protocol, proxy: opts.Proxy}
+	}
+
+	switch opts.Protocol {
+	case ProtocolH2:
+		// Unix socket URLs are always cleartext.
+		if opts.Socket != "" {
+			return nil, fmt.Errorf("protocol %v can't be used with a unix socket (use %v)", ProtocolH2, ProtocolH2C)
+		}
+		cfg := opts.TLS.Clone()
+		cfg.NextProtos = []string{http2.NextProtoTLS}
+		return h2(&http2.Transport{
+			DisableCompression: true,
+			TLSClientConfig:    cfg,
+			DialTLSContext: func(ctx context.Context, network, addr string, cfg *tls.Config) (net.Conn, error) {
+				c, err := d.DialTLSContext(ctx, network, addr, cfg)
+				if err != nil {
+					return nil, err
+				}
+				if p := c.(*LoggerConn).ConnectionState().NegotiatedProtocol; p != http2.NextProtoTLS {
+					c.Close()
+					return nil, fmt.Errorf("server didn't negotiate h2 (got '%v')", p)
+				}
+				return c, nil
+			},
+		}), nil
+
+	case ProtocolH2C:
+		return h2(&http2.Transport{
+			AllowHTTP:          true,
+			DisableCompression: true,
+			DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+				return d.DialContext(ctx, network, addr)
+			},
+		}), nil
+
+	case "", ProtocolHTTP1, "http/1.1":
+		proxy := http.ProxyFromEnvironment
+		if opts.Proxy != nil {
+			proxy = opts.Proxy.Proxy
+		}
+		if opts.Socket != "" {
+			proxy = nil
+		}
+
+		// Only offer HTTP/1.1. The HTTP/2 support in http.Transport
+		// can't use our logging connections.
+		cfg := opts.TLS.Clone()
+		cfg.NextProtos = []string{"http/1.1"}
+
+		return &http.Transport{
+			DialContext: d.DialContext,
+			DialTLSContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return d.DialTLSContext(ctx, network, addr, cfg)
+			},
+
+			// This is used when tunneling through a proxy.
+			TLSClientConfig: cfg,
+			TLSNextProto:    map[string]func(string, *tls.Conn) http.RoundTripper{},
+
+			DisableCompression:    true,
+			MaxIdleConns:          100,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: 1 * time.Second,
+			Proxy:                 proxy,
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported protocol '%v' (valid: %v, %v, %v)",
+			opts.Protocol, ProtocolHTTP1, ProtocolH2, ProtocolH2C)
+	}
+}
+
+// noProxyTransport fails the requests the proxy configuration would
+// send through a proxy instead of silently connecting directly.
+type noProxyTransport struct {
+	http.RoundTripper
+	protocol string
+	proxy    *ProxyConfig
+}
+
+func (t *noProxyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	u, err := t.proxy.Proxy(req)
+	if err != nil {
+		return nil, err
+	}
+	if u != nil {
+		return nil, fmt.Errorf("protocol %v can't be used with a proxy (%v)", t.protocol, u.Redacted())
+	}
+	return t.RoundTripper.RoundTrip(req)
+}
+
+// unixSocketURL rewrites a unix socket url in place to an http url
+// and returns the path to the socket. The path of the request
+// follows the socket path, separated by a colon
+// (e.g. unix:///var/run/docker.sock:/v1.41/containers/json).
+func unixSocketURL(u *url.URL) string {
+	socket, path := u.Path, "/"
+	if i := strings.Index(u.Path, ":"); i >= 0 {
+		socket, path = u.Path[:i], u.Path[i+1:]
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	u.Scheme = "http"
+	u.Host = "localhost"
+	u.Path = path
+	u.RawPath = ""
+	return socket
 }
 
 type LoggerConn struct {
@@ -51,7 +371,7 @@ type LoggerConn struct {
 // Read implements the Read method.
 func (l *LoggerConn) Read(b []byte) (n int, err error) {
 	n, err = l.conn.Read(b)
-	l.in.Write(b)
+	l.in.Write(b[:n])
 	return n, err
 }
 
@@ -61,6 +381,15 @@ func (l *LoggerConn) Write(b []byte) (n int, err error) {
 	return l.conn.Write(b)
 }
 
+// ConnectionState returns the TLS state of the underlying
+// connection. It's empty if the connection isn't using TLS.
+func (l *LoggerConn) ConnectionState() tls.ConnectionState {
+	if c, ok := l.conn.(*tls.Conn); ok {
+		return c.ConnectionState()
+	}
+	return tls.ConnectionState{}
+}
+
 // Close implements the Close method.
 func (l *LoggerConn) Close() error {
 	return l.conn.Close()
@@ -88,5 +417,5 @@ func (l *LoggerConn) SetReadDeadline(t time.Time) error {
 
 // SetWriteDeadline implements the SetWriteDeadline method.
 func (l *LoggerConn) SetWriteDeadline(t time.Time) error {
-	return l.SetWriteDeadline(t)
+	return l.conn.SetWriteDeadline(t)
 }
@@ -0,0 +1,1058 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gookit/color"
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// OpenAPI is the part of an OpenAPI 3 document we use. Schemas are
+// kept as YAML nodes so they can be used as JSON Schemas.
+type OpenAPI struct {
+	OpenAPI    string                `yaml:"openapi"`
+	Swagger    string                `yaml:"swagger"`
+	Info       OpenAPIInfo           `yaml:"info"`
+	Servers    []OpenAPIServer       `yaml:"servers"`
+	Paths      yaml.Node             `yaml:"paths"`
+	Components OpenAPIComponents     `yaml:"components"`
+	Security   []map[string][]string `yaml:"security"`
+
+	root      *yaml.Node
+	paths     []OpenAPIPath
+	validator *schemaValidator
+}
+
+type OpenAPIInfo struct {
+	Title   string `yaml:"title"`
+	Version string `yaml:"version"`
+}
+
+type OpenAPIServer struct {
+	URL         string `yaml:"url"`
+	Description string `yaml:"description"`
+	Variables   map[string]struct {
+		Default string `yaml:"default"`
+	} `yaml:"variables"`
+}
+
+type OpenAPIComponents struct {
+	SecuritySchemes map[string]OpenAPISecurityScheme `yaml:"securitySchemes"`
+}
+
+type OpenAPIPathItem struct {
+	Ref        string             `yaml:"$ref"`
+	Parameters []OpenAPIParameter `yaml:"parameters"`
+	Get        *OpenAPIOperation  `yaml:"get"`
+	Put        *OpenAPIOperation  `yaml:"put"`
+	Post       *OpenAPIOperation  `yaml:"post"`
+	Delete     *OpenAPIOperation  `yaml:"delete"`
+	Options    *OpenAPIOperation  `yaml:"options"`
+	Head       *OpenAPIOperation  `yaml:"head"`
+	Patch      *OpenAPIOperation  `yaml:"patch"`
+	Trace      *OpenAPIOperation  `yaml:"trace"`
+}
+
+// Operations are the operations of the path by method.
+func (p OpenAPIPathItem) Operations() map[string]*OpenAPIOperation {
+	ops := map[string]*OpenAPIOperation{
+		"GET": p.Get, "PUT": p.Put, "POST": p.Post, "DELETE": p.Delete,
+		"OPTIONS": p.Options, "HEAD": p.Head, "PATCH": p.Patch, "TRACE": p.Trace,
+	}
+	for m, op := range ops {
+		if op == nil {
+			delete(ops, m)
+		}
+	}
+	return ops
+}
+
+// openAPIMethods is the order operations are listed in.
+var openAPIMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS", "TRACE"}
+
+type OpenAPIOperation struct {
+	OperationID string                     `yaml:"operationId"`
+	Summary     string                     `yaml:"summary"`
+	Description string                     `yaml:"description"`
+	Parameters  []OpenAPIParameter         `yaml:"parameters"`
+	RequestBody *OpenAPIRequestBody        `yaml:"requestBody"`
+	Responses   map[string]OpenAPIResponse `yaml:"responses"`
+	Security    *[]map[string][]string     `yaml:"security"`
+}
+
+type OpenAPIParameter struct {
+	Ref      string                    `yaml:"$ref"`
+	Name     string                    `yaml:"name"`
+	In       string                    `yaml:"in"`
+	Required bool                      `yaml:"required"`
+	Schema   yaml.Node                 `yaml:"schema"`
+	Example  yaml.Node                 `yaml:"example"`
+	Examples map[string]OpenAPIExample `yaml:"examples"`
+}
+
+type OpenAPIRequestBody struct {
+	Ref     string                      `yaml:"$ref"`
+	Content map[string]OpenAPIMediaType `yaml:"content"`
+}
+
+type OpenAPIResponse struct {
+	Ref     string                      `yaml:"$ref"`
+	Content map[string]OpenAPIMediaType `yaml:"content"`
+}
+
+type OpenAPIMediaType struct {
+	Schema   yaml.Node                 `yaml:"schema"`
+	Example  yaml.Node                 `yaml:"example"`
+	Examples map[string]OpenAPIExample `yaml:"examples"`
+}
+
+type OpenAPIExample struct {
+	Ref   string    `yaml:"$ref"`
+	Value yaml.Node `yaml:"value"`
+}
+
+type OpenAPISecurityScheme struct {
+	Ref    string `yaml:"$ref"`
+	Type   string `yaml:"type"`
+	Scheme string `yaml:"scheme"`
+	Name   string `yaml:"name"`
+	In     string `yaml:"in"`
+}
+
+// OpenAPIPath is a path template and its operations.
+type OpenAPIPath struct {
+	Template string
+	Item     OpenAPIPathItem
+}
+
+// LoadOpenAPI reads an OpenAPI 3 document (YAML or JSON).
+func LoadOpenAPI(path string) (*OpenAPI, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	root := &yaml.Node{}
+	if err := yaml.Unmarshal(buf, root); err != nil {
+		return nil, fmt.Errorf("parsing %v: %v", path, err)
+	}
+	o := &OpenAPI{root: root}
+	if err := root.Decode(o); err != nil {
+		return nil, fmt.Errorf("parsing %v: %v", path, err)
+	}
+	if o.Swagger != "" || !strings.HasPrefix(o.OpenAPI, "3.") {
+		return nil, fmt.Errorf("%v isn't an OpenAPI 3 document", path)
+	}
+	return o, nil
+}
+
+// PathItems are the paths in the order of the document. Referenced
+// parameters are resolved.
+func (o *OpenAPI) PathItems() ([]OpenAPIPath, error) {
+	paths := []OpenAPIPath{}
+	for x := 0; x+1 < len(o.Paths.Content); x += 2 {
+		p := OpenAPIPath{Template: o.Paths.Content[x].Value}
+		if err := o.decode(o.Paths.Content[x+1], &p.Item); err != nil {
+			return nil, fmt.Errorf("path %v: %v", p.Template, err)
+		}
+		params, err := o.parameters(p.Item.Parameters)
+		if err != nil {
+			return nil, fmt.Errorf("path %v: %v", p.Template, err)
+		}
+		p.Item.Parameters = params
+		for m, op := range p.Item.Operations() {
+			if op.Parameters, err = o.parameters(op.Parameters); err != nil {
+				return nil, fmt.Errorf("%v %v: %v", m, p.Template, err)
+			}
+			if op.RequestBody != nil && op.RequestBody.Ref != "" {
+				if err := o.decode(&yaml.Node{Kind: yaml.MappingNode, Content: refNode(op.RequestBody.Ref)}, op.RequestBody); err != nil {
+					return nil, fmt.Errorf("%v %v: %v", m, p.Template, err)
+				}
+			}
+			for code, r := range op.Responses {
+				if r.Ref == "" {
+					continue
+				}
+				if err := o.decode(&yaml.Node{Kind: yaml.MappingNode, Content: refNode(r.Ref)}, &r); err != nil {
+					return nil, fmt.Errorf("%v %v: %v", m, p.Template, err)
+				}
+				op.Responses[code] = r
+			}
+		}
+		paths = append(paths, p)
+	}
+	return paths, nil
+}
+
+func refNode(ref string) []*yaml.Node {
+	return []*yaml.Node{
+		{Kind: yaml.ScalarNode, Value: "$ref"},
+		{Kind: yaml.ScalarNode, Value: ref},
+	}
+}
+
+// parameters resolves referenced parameters.
+func (o *OpenAPI) parameters(params []OpenAPIParameter) ([]OpenAPIParameter, error) {
+	resolved := make([]OpenAPIParameter, len(params))
+	for x, p := range params {
+		if p.Ref != "" {
+			if err := o.decode(&yaml.Node{Kind: yaml.MappingNode, Content: refNode(p.Ref)}, &p); err != nil {
+				return nil, err
+			}
+		}
+		resolved[x] = p
+	}
+	return resolved, nil
+}
+
+// decode decodes the node, following it if it's a reference.
+func (o *OpenAPI) decode(n *yaml.Node, v interface{}) error {
+	n, err := o.Deref(n)
+	if err != nil {
+		return err
+	}
+	return n.Decode(v)
+}
+
+// Deref follows $ref until it gets to a node that isn't a reference.
+// Only references within the document are supported.
+func (o *OpenAPI) Deref(n *yaml.Node) (*yaml.Node, error) {
+	for x := 0; n != nil; x++ {
+		if n.Kind == yaml.AliasNode {
+			n = n.Alias
+			continue
+		}
+		ref := nodeRef(n)
+		if ref == "" {
+			return n, nil
+		}
+		if x > 32 {
+			return nil, fmt.Errorf("too many references at %v", ref)
+		}
+		target, err := lookupPointer(o.root, ref)
+		if err != nil {
+			return nil, err
+		}
+		n = target
+	}
+	return n, nil
+}
+
+// nodeRef is the $ref of a mapping.
+func nodeRef(n *yaml.Node) string {
+	if n == nil || n.Kind != yaml.MappingNode {
+		return ""
+	}
+	for x := 0; x+1 < len(n.Content); x += 2 {
+		if n.Content[x].Value == "$ref" {
+			return n.Content[x+1].Value
+		}
+	}
+	return ""
+}
+
+// lookupPointer finds the node of a reference like
+// #/components/schemas/Pet.
+func lookupPointer(root *yaml.Node, ref string) (*yaml.Node, error) {
+	if !strings.HasPrefix(ref, "#") {
+		return nil, fmt.Errorf("external reference %v isn't supported", ref)
+	}
+	n := root
+	if n.Kind == yaml.DocumentNode && len(n.Content) > 0 {
+		n = n.Content[0]
+	}
+	pointer := strings.TrimPrefix(ref, "#")
+	if pointer == "" {
+		return n, nil
+	}
+	for _, part := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		part, _ = url.PathUnescape(part)
+		part = strings.ReplaceAll(strings.ReplaceAll(part, "~1", "/"), "~0", "~")
+		var next *yaml.Node
+		switch n.Kind {
+		case yaml.MappingNode:
+			for x := 0; x+1 < len(n.Content); x += 2 {
+				if n.Content[x].Value == part {
+					next = n.Content[x+1]
+				}
+			}
+		case yaml.SequenceNode:
+			var i int
+			if _, err := fmt.Sscanf(part, "%d", &i); err == nil && i >= 0 && i < len(n.Content) {
+				next = n.Content[i]
+			}
+		}
+		if next == nil {
+			return nil, fmt.Errorf("reference %v not found", ref)
+		}
+		n = next
+	}
+	return n, nil
+}
+
+// nodeValue converts a YAML node to the values decodeJSON makes so it
+// can be encoded as JSON in the same order.
+func nodeValue(n *yaml.Node) interface{} {
+	if n == nil || n.Kind == 0 {
+		return nil
+	}
+	switch n.Kind {
+	case yaml.DocumentNode:
+		if len(n.Content) == 0 {
+			return nil
+		}
+		return nodeValue(n.Content[0])
+	case yaml.AliasNode:
+		return nodeValue(n.Alias)
+	case yaml.MappingNode:
+		o := newJSONObject()
+		for x := 0; x+1 < len(n.Content); x += 2 {
+			o.Set(n.Content[x].Value, nodeValue(n.Content[x+1]))
+		}
+		return o
+	case yaml.SequenceNode:
+		a := []interface{}{}
+		for _, c := range n.Content {
+			a = append(a, nodeValue(c))
+		}
+		return a
+	}
+	switch n.ShortTag() {
+	case "!!null":
+		return nil
+	case "!!bool":
+		var b bool
+		n.Decode(&b)
+		return b
+	case "!!int", "!!float":
+		if json.Valid([]byte(n.Value)) {
+			return json.Number(n.Value)
+		}
+		var f float64
+		n.Decode(&f)
+		return json.Number(fmt.Sprintf("%v", f))
+	}
+	return n.Value
+}
+
+// Example makes an example value for the schema. Examples and
+// defaults in the schema are used when there are some.
+func (o *OpenAPI) Example(schema *yaml.Node) interface{} {
+	return o.example(schema, 0)
+}
+
+func (o *OpenAPI) example(schema *yaml.Node, depth int) interface{} {
+	n, err := o.Deref(schema)
+	if err != nil || n == nil || n.Kind != yaml.MappingNode || depth > 8 {
+		return nil
+	}
+	s := map[string]*yaml.Node{}
+	for x := 0; x+1 < len(n.Content); x += 2 {
+		s[n.Content[x].Value] = n.Content[x+1]
+	}
+
+	if v, ok := s["example"]; ok {
+		return nodeValue(v)
+	}
+	if v, ok := s["examples"]; ok && v.Kind == yaml.SequenceNode && len(v.Content) > 0 {
+		return nodeValue(v.Content[0])
+	}
+	if v, ok := s["default"]; ok {
+		return nodeValue(v)
+	}
+	if v, ok := s["const"]; ok {
+		return nodeValue(v)
+	}
+	if v, ok := s["enum"]; ok && len(v.Content) > 0 {
+		return nodeValue(v.Content[0])
+	}
+	for _, k := range []string{"oneOf", "anyOf"} {
+		if v, ok := s[k]; ok && len(v.Content) > 0 {
+			return o.example(v.Content[0], depth+1)
+		}
+	}
+	if v, ok := s["allOf"]; ok {
+		all := newJSONObject()
+		for _, c := range v.Content {
+			if e, ok := o.example(c, depth+1).(*jsonObject); ok {
+				for _, k := range e.keys {
+					all.Set(k, e.values[k])
+				}
+			}
+		}
+		return all
+	}
+
+	t := ""
+	if v, ok := s["type"]; ok {
+		t = v.Value
+		if v.Kind == yaml.SequenceNode && len(v.Content) > 0 {
+			// 3.1 types can be lists like [string, "null"].
+			t = v.Content[0].Value
+		}
+	}
+	if t == "" {
+		if _, ok := s["properties"]; ok {
+			t = "object"
+		} else if _, ok := s["items"]; ok {
+			t = "array"
+		}
+	}
+
+	switch t {
+	case "object":
+		obj := newJSONObject()
+		if props, ok := s["properties"]; ok {
+			for x := 0; x+1 < len(props.Content); x += 2 {
+				if p, err := o.Deref(props.Content[x+1]); err == nil && schemaFlag(p, "readOnly") {
+					continue
+				}
+				obj.Set(props.Content[x].Value, o.example(props.Content[x+1], depth+1))
+			}
+		}
+		return obj
+	case "array":
+		if items, ok := s["items"]; ok {
+			return []interface{}{o.example(items, depth+1)}
+		}
+		return []interface{}{}
+	case "integer", "number":
+		return json.Number("0")
+	case "boolean":
+		return false
+	case "null":
+		return nil
+	case "string":
+		format := ""
+		if v, ok := s["format"]; ok {
+			format = v.Value
+		}
+		switch format {
+		case "date-time":
+			return "2006-01-02T15:04:05Z"
+		case "date":
+			return "2006-01-02"
+		case "uuid":
+			return "00000000-0000-0000-0000-000000000000"
+		case "email":
+			return "user@example.com"
+		case "uri", "url":
+			return "https://example.com"
+		}
+		return "string"
+	}
+	return nil
+}
+
+// schemaFlag determines if the boolean keyword of the schema is true.
+func schemaFlag(n *yaml.Node, key string) bool {
+	for x := 0; n != nil && x+1 < len(n.Content); x += 2 {
+		if n.Content[x].Value == key {
+			return n.Content[x+1].Value == "true"
+		}
+	}
+	return false
+}
+
+// parameterExample is the example value of the parameter as a string.
+func (o *OpenAPI) parameterExample(p OpenAPIParameter) string {
+	var v interface{}
+	switch {
+	case p.Example.Kind != 0:
+		v = nodeValue(&p.Example)
+	case len(p.Examples) > 0:
+		for _, k := range sortedKeys(p.Examples) {
+			v = o.exampleValue(p.Examples[k])
+			break
+		}
+	case p.Schema.Kind != 0:
+		v = o.Example(&p.Schema)
+	}
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case *jsonObject, []interface{}:
+		return string(encodeJSON(t, "", PlainPalette))
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+func (o *OpenAPI) exampleValue(e OpenAPIExample) interface{} {
+	if e.Ref != "" {
+		o.decode(&yaml.Node{Kind: yaml.MappingNode, Content: refNode(e.Ref)}, &e)
+	}
+	return nodeValue(&e.Value)
+}
+
+func sortedKeys(m map[string]OpenAPIExample) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// jsonMediaType determines if the media type is JSON (including
+// types like application/problem+json).
+func jsonMediaType(mt string) bool {
+	mt = strings.ToLower(strings.TrimSpace(strings.Split(mt, ";")[0]))
+	return mt == "application/json" || strings.HasSuffix(mt, "+json")
+}
+
+// pickMediaType chooses the media type requests are made with,
+// preferring JSON.
+func pickMediaType(content map[string]OpenAPIMediaType) string {
+	types := make([]string, 0, len(content))
+	for mt := range content {
+		types = append(types, mt)
+	}
+	sort.Strings(types)
+	for _, mt := range types {
+		if jsonMediaType(mt) {
+			return mt
+		}
+	}
+	if len(types) > 0 {
+		return types[0]
+	}
+	return ""
+}
+
+var camelCase = regexp.MustCompile(`([a-z0-9])([A-Z])`)
+
+// openAPIImport converts an OpenAPI document to requests and
+// environments.
+type openAPIImport struct {
+	spec *OpenAPI
+	// API is the name of the environment variables of the API
+	// (environment.<api>.url).
+	API      string
+	Requests []NamedRequest
+	// Variables are the environment variables the requests use and
+	// their example values.
+	Variables map[string]string
+	Warnings  []string
+}
+
+func (i *openAPIImport) warn(where, format string, a ...interface{}) {
+	i.Warnings = append(i.Warnings, where+": "+fmt.Sprintf(format, a...))
+}
+
+// variable adds an environment variable for the API and returns its
+// placeholder.
+func (i *openAPIImport) variable(name, example string) string {
+	if v, ok := i.Variables[name]; !ok || v == "" {
+		i.Variables[name] = example
+	}
+	return "{{environment." + i.API + "." + name + "}}"
+}
+
+var pathParameter = regexp.MustCompile(`\{([^{}]+)\}`)
+
+func (i *openAPIImport) Convert() error {
+	paths, err := i.spec.PathItems()
+	if err != nil {
+		return err
+	}
+	for _, p := range paths {
+		ops := p.Item.Operations()
+		for _, m := range openAPIMethods {
+			if op, ok := ops[m]; ok {
+				i.operation(p, m, op)
+			}
+		}
+	}
+	return nil
+}
+
+func (i *openAPIImport) operation(p OpenAPIPath, method string, op *OpenAPIOperation) {
+	where := method + " " + p.Template
+	r := Request{
+		Method:         method,
+		Description:    op.Summary,
+		Headers:        map[string]string{},
+		Authentication: map[string]string{},
+		Query:          map[string]string{},
+	}
+	if r.Description == "" {
+		r.Description = op.Description
+	}
+
+	// Operation parameters override the ones of the path.
+	params := map[string]OpenAPIParameter{}
+	order := []string{}
+	for _, param := range append(append([]OpenAPIParameter{}, p.Item.Parameters...), op.Parameters...) {
+		key := param.In + ":" + param.Name
+		if _, ok := params[key]; !ok {
+			order = append(order, key)
+		}
+		params[key] = param
+	}
+
+	examples := map[string]string{}
+	for _, key := range order {
+		param := params[key]
+		example := i.spec.parameterExample(param)
+		switch param.In {
+		case "path":
+			examples[param.Name] = example
+		case "query":
+			// Optional parameters are only added if they have an example.
+			if param.Required {
+				r.Query[param.Name] = i.variable(param.Name, example)
+			} else if param.Example.Kind != 0 || len(param.Examples) > 0 {
+				r.Query[param.Name] = example
+			}
+		case "header":
+			if param.Required {
+				r.Headers[param.Name] = i.variable(param.Name, example)
+			}
+		case "cookie":
+			if param.Required {
+				i.warn(where, "cookie parameter '%v' not imported", param.Name)
+			}
+		}
+	}
+	path := pathParameter.ReplaceAllStringFunc(p.Template, func(m string) string {
+		name := strings.Trim(m, "{}")
+		return i.variable(name, examples[name])
+	})
+	r.URL = "{{environment." + i.API + ".url}}" + path
+
+	security := i.spec.Security
+	if op.Security != nil {
+		security = *op.Security
+	}
+	i.auth(where, &r, security)
+	if op.RequestBody != nil {
+		i.body(where, &r, op.RequestBody)
+	}
+
+	name := cleanName(camelCase.ReplaceAllString(op.OperationID, "$1-$2"))
+	if name == "" {
+		name = requestName(method, p.Template)
+	}
+	i.Requests = append(i.Requests, NamedRequest{Name: name, Request: r})
+}
+
+// auth uses the first security requirement that we support.
+func (i *openAPIImport) auth(where string, r *Request, security []map[string][]string) {
+	if len(security) == 0 {
+		return
+	}
+	for _, req := range security {
+		names := make([]string, 0, len(req))
+		for name := range req {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		if len(names) == 0 {
+			// {} makes authentication optional.
+			return
+		}
+		if len(names) > 1 {
+			i.warn(where, "only the first of the security schemes %v is used", strings.Join(names, ", "))
+		}
+
+		s, ok := i.spec.Components.SecuritySchemes[names[0]]
+		if !ok {
+			i.warn(where, "security scheme '%v' not found", names[0])
+			continue
+		}
+		if s.Ref != "" {
+			i.spec.decode(&yaml.Node{Kind: yaml.MappingNode, Content: refNode(s.Ref)}, &s)
+		}
+		switch {
+		case s.Type == "http" && strings.EqualFold(s.Scheme, "bearer"),
+			s.Type == "oauth2", s.Type == "openIdConnect":
+			r.Authentication["type"] = "bearer"
+			r.Authentication["token"] = i.variable("token", "")
+		case s.Type == "http" && strings.EqualFold(s.Scheme, "basic"):
+			r.Authentication["type"] = "basic"
+			r.Authentication["username"] = i.variable("username", "")
+			r.Authentication["password"] = i.variable("password", "")
+		case s.Type == "apiKey" && s.In == "header":
+			r.Headers[s.Name] = i.variable(cleanName(s.Name), "")
+		case s.Type == "apiKey" && s.In == "query":
+			r.Query[s.Name] = i.variable(cleanName(s.Name), "")
+		case s.Type == "apiKey" && s.In == "cookie":
+			r.Headers["Cookie"] = s.Name + "=" + i.variable(cleanName(s.Name), "")
+		default:
+			i.warn(where, "%v security scheme '%v' not supported", s.Type, names[0])
+			continue
+		}
+		return
+	}
+}
+
+func (i *openAPIImport) body(where string, r *Request, rb *OpenAPIRequestBody) {
+	mt := pickMediaType(rb.Content)
+	if mt == "" {
+		return
+	}
+	media := rb.Content[mt]
+	var v interface{}
+	switch {
+	case media.Example.Kind != 0:
+		v = nodeValue(&media.Example)
+	case len(media.Examples) > 0:
+		v = i.spec.exampleValue(media.Examples[sortedKeys(media.Examples)[0]])
+	default:
+		v = i.spec.Example(&media.Schema)
+	}
+	r.Headers["Content-Type"] = mt
+
+	obj, isObj := v.(*jsonObject)
+	switch base := strings.ToLower(strings.Split(mt, ";")[0]); {
+	case jsonMediaType(mt):
+		r.Body = Body{Type: "raw", Value: string(encodeJSON(v, "  ", PlainPalette))}
+	case base == "application/x-www-form-urlencoded" && isObj:
+		q := []string{}
+		for _, k := range obj.keys {
+			q = append(q, url.QueryEscape(k)+"="+url.QueryEscape(formValue(obj.values[k])))
+		}
+		r.Body = Body{Type: "raw", Value: strings.Join(q, "&")}
+	case base == "multipart/form-data" && isObj:
+		// The multipart body sets its own content type.
+		delete(r.Headers, "Content-Type")
+		files := i.spec.binaryProperties(&media.Schema)
+		parts := []MultiPartPart{}
+		for _, k := range obj.keys {
+			part := MultiPartPart{Type: "raw", Name: k, Value: formValue(obj.values[k])}
+			if files[k] {
+				part.Type, part.Value = "file", i.variable(cleanName(k), "")
+			}
+			parts = append(parts, part)
+		}
+		buf, err := yaml.Marshal(parts)
+		if err != nil {
+			i.warn(where, "body not imported: %v", err)
+			return
+		}
+		r.Body = Body{Type: "multipart", Value: string(buf)}
+	default:
+		s, ok := v.(string)
+		if !ok {
+			i.warn(where, "no example for the %v body", mt)
+			return
+		}
+		r.Body = Body{Type: "raw", Value: s}
+	}
+}
+
+// binaryProperties are the properties of an object schema that are
+// files.
+func (o *OpenAPI) binaryProperties(schema *yaml.Node) map[string]bool {
+	files := map[string]bool{}
+	n, err := o.Deref(schema)
+	if err != nil || n == nil {
+		return files
+	}
+	for x := 0; x+1 < len(n.Content); x += 2 {
+		if n.Content[x].Value != "properties" {
+			continue
+		}
+		props := n.Content[x+1]
+		for y := 0; y+1 < len(props.Content); y += 2 {
+			p, err := o.Deref(props.Content[y+1])
+			if err != nil || p == nil {
+				continue
+			}
+			for z := 0; z+1 < len(p.Content); z += 2 {
+				if p.Content[z].Value == "format" && p.Content[z+1].Value == "binary" {
+					files[props.Content[y].Value] = true
+				}
+			}
+		}
+	}
+	return files
+}
+
+// formValue is the value of a form field.
+func formValue(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case *jsonObject, []interface{}:
+		return string(encodeJSON(t, "", PlainPalette))
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// Environments are stubs of environments for each server of the API.
+func (i *openAPIImport) Environments() map[string]Environment {
+	servers := i.spec.Servers
+	if len(servers) == 0 {
+		servers = []OpenAPIServer{{URL: "http://localhost"}}
+		i.warn(i.API, "no servers; the environment uses http://localhost")
+	}
+
+	envs := map[string]Environment{}
+	for x, s := range servers {
+		u := s.URL
+		for name, v := range s.Variables {
+			u = strings.ReplaceAll(u, "{"+name+"}", v.Default)
+		}
+		if !strings.Contains(u, "://") {
+			i.warn(i.API, "server %v is relative; the environment uses http://localhost", s.URL)
+			u = "http://localhost" + u
+		}
+
+		vars := map[string]interface{}{"url": strings.TrimSuffix(u, "/")}
+		for k, v := range i.Variables {
+			vars[k] = v
+		}
+
+		name := cleanName(s.Description)
+		if name == "" {
+			name = i.API
+			if x > 0 {
+				name = fmt.Sprintf("%v-%v", i.API, x+1)
+			}
+		}
+		if _, ok := envs[name]; ok {
+			name = fmt.Sprintf("%v-%v", name, x+1)
+		}
+		envs[name] = Environment{i.API: vars}
+	}
+	return envs
+}
+
+func openapiimport(c *cli.Context) error {
+	if c.Args().Len() != 1 {
+		return cli.Exit(color.Red.Sprintf("import expects an OpenAPI document"), -1)
+	}
+	spec, err := LoadOpenAPI(c.Args().First())
+	if err != nil {
+		return cli.Exit(color.Red.Sprintf("reading OpenAPI document: %v", err), -1)
+	}
+
+	folder := c.String("folder")
+	if folder == "" {
+		folder = cleanName(spec.Info.Title)
+	}
+	api := cleanName(filepath.Base(folder))
+	if api == "" {
+		api = "api"
+	}
+
+	i := &openAPIImport{spec: spec, API: api, Variables: map[string]string{}}
+	if err := i.Convert(); err != nil {
+		return cli.Exit(color.Red.Sprintf("converting OpenAPI document: %v", err), -1)
+	}
+
+	base := filepath.Join(configDir(c), filepath.FromSlash(folder))
+	path := filepath.Join(base, c.String("file"))
+	names, err := appendRequests(path, i.Requests)
+	if err != nil {
+		return cli.Exit(color.Red.Sprintf("adding requests to %v: %v", path, err), -1)
+	}
+	printImported(path, "requests", names, nil)
+
+	envs := i.Environments()
+	path = filepath.Join(base, c.String("environment-file"))
+	names, err = appendEnvironments(path, envs)
+	if err != nil {
+		return cli.Exit(color.Red.Sprintf("adding environments to %v: %v", path, err), -1)
+	}
+	printImported(path, "environments", names, i.Warnings)
+	return nil
+}
+
+// openAPIOperation is an operation found for a request.
+type openAPIOperation struct {
+	Method    string
+	Template  string
+	Operation *OpenAPIOperation
+}
+
+// FindOperation matches the method and path of a request to an
+// operation. The path of the servers is removed first. Paths without
+// parameters are preferred to ones with them.
+func (o *OpenAPI) FindOperation(method, path string) (*openAPIOperation, error) {
+	if o.paths == nil {
+		paths, err := o.PathItems()
+		if err != nil {
+			return nil, err
+		}
+		o.paths = paths
+	}
+
+	candidates := []string{path}
+	for _, s := range o.Servers {
+		u := s.URL
+		for name, v := range s.Variables {
+			u = strings.ReplaceAll(u, "{"+name+"}", v.Default)
+		}
+		if pu, err := url.Parse(u); err == nil {
+			base := strings.TrimSuffix(pu.Path, "/")
+			if base != "" && strings.HasPrefix(path, base+"/") {
+				candidates = append(candidates, strings.TrimPrefix(path, base))
+			}
+		}
+	}
+
+	var found *openAPIOperation
+	best := -1
+	for _, p := range o.paths {
+		re, literal := templatePattern(p.Template)
+		for _, c := range candidates {
+			if !re.MatchString(c) || literal <= best {
+				continue
+			}
+			best = literal
+			found = &openAPIOperation{Method: method, Template: p.Template, Operation: p.Item.Operations()[method]}
+		}
+	}
+	return found, nil
+}
+
+// templatePattern makes a pattern for a path template and counts its
+// literal characters.
+func templatePattern(template string) (*regexp.Regexp, int) {
+	pattern := &strings.Builder{}
+	literal := 0
+	last := 0
+	for _, m := range pathParameter.FindAllStringIndex(template, -1) {
+		pattern.WriteString(regexp.QuoteMeta(template[last:m[0]]))
+		pattern.WriteString("[^/]+")
+		literal += m[0] - last
+		last = m[1]
+	}
+	pattern.WriteString(regexp.QuoteMeta(template[last:]))
+	literal += len(template) - last
+	return regexp.MustCompile("^" + strings.TrimSuffix(pattern.String(), "/") + "/?$"), literal
+}
+
+// ValidateResponse checks the status code, content type and body of
+// the response against the operation.
+func (o *OpenAPI) ValidateResponse(op *openAPIOperation, resp *Response) []string {
+	if op.Operation == nil {
+		return []string{fmt.Sprintf("%v isn't an operation of %v", op.Method, op.Template)}
+	}
+
+	code := fmt.Sprintf("%v", resp.StatusCode)
+	r, ok := op.Operation.Responses[code]
+	if !ok {
+		r, ok = op.Operation.Responses[code[:1]+"XX"]
+	}
+	if !ok {
+		r, ok = op.Operation.Responses[code[:1]+"xx"]
+	}
+	if !ok {
+		r, ok = op.Operation.Responses["default"]
+	}
+	if !ok {
+		return []string{fmt.Sprintf("status %v isn't a documented response", resp.StatusCode)}
+	}
+	if len(r.Content) == 0 {
+		return nil
+	}
+
+	contentType := strings.Trim(resp.Headers["Content-Type"], "[]")
+	mt, media, ok := matchMediaType(r.Content, contentType)
+	if !ok {
+		types := make([]string, 0, len(r.Content))
+		for t := range r.Content {
+			types = append(types, t)
+		}
+		sort.Strings(types)
+		return []string{fmt.Sprintf("content type '%v' isn't one of %v", contentType, strings.Join(types, ", "))}
+	}
+	if media.Schema.Kind == 0 || !jsonMediaType(mt) && !jsonMediaType(contentType) {
+		return nil
+	}
+
+	body, err := decodeJSON([]byte(resp.Body))
+	if err != nil {
+		return []string{fmt.Sprintf("body isn't valid JSON: %v", err)}
+	}
+	if o.validator == nil {
+		o.validator = newSchemaValidator(nodeValue(o.root), strings.HasPrefix(o.OpenAPI, "3.0"))
+	}
+	violations := []string{}
+	for _, e := range o.validator.Validate(nodeValue(&media.Schema), body) {
+		violations = append(violations, "body "+e.String())
+	}
+	return violations
+}
+
+// matchMediaType finds the documented media type of a content type.
+// Ranges like application/* and */* are used if there isn't an exact
+// match.
+func matchMediaType(content map[string]OpenAPIMediaType, contentType string) (string, OpenAPIMediaType, bool) {
+	ct := strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	main := strings.Split(ct, "/")[0]
+	for _, candidate := range []string{ct, main + "/*", "*/*"} {
+		for mt, media := range content {
+			if strings.ToLower(strings.TrimSpace(strings.Split(mt, ";")[0])) == candidate {
+				return mt, media, true
+			}
+		}
+	}
+	return "", OpenAPIMediaType{}, false
+}
+
+// loadOpenAPI loads the OpenAPI document in the preferences (openapi)
+// once. Relative paths are relative to the config folder.
+func loadOpenAPI(c *cli.Context, cfg *Config) (*OpenAPI, error) {
+	if cfg.openAPI != nil {
+		return cfg.openAPI, nil
+	}
+	path := cfg.Preferences["openapi"]
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(configDir(c), path)
+	}
+	spec, err := LoadOpenAPI(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg.openAPI = spec
+	return spec, nil
+}
+
+// checkOpenAPI validates the response of an HTTP request against the
+// OpenAPI document in the preferences and prints what doesn't match.
+func checkOpenAPI(c *cli.Context, cfg *Config, req Request, resp *Response) ([]string, error) {
+	spec, err := loadOpenAPI(c, cfg)
+	if err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(req.URL)
+	if err != nil {
+		return nil, err
+	}
+	method := strings.ToUpper(req.Method)
+	op, err := spec.FindOperation(method, u.Path)
+	if err != nil {
+		return nil, err
+	}
+	if op == nil {
+		color.Yellow.Printf("<openapi: no operation matches %v %v>\n", method, u.Path)
+		return nil, nil
+	}
+
+	violations := spec.ValidateResponse(op, resp)
+	if len(violations) == 0 {
+		color.Green.Printf("openapi: %v %v ok\n", method, op.Template)
+		return nil, nil
+	}
+	color.Red.Printf("openapi: %v %v\n", method, op.Template)
+	for x, v := range violations {
+		violations[x] = "openapi: " + v
+		color.Red.Printf("  <%v>\n", v)
+	}
+	return violations, nil
+}
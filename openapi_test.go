@@ -0,0 +1,153 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testOpenAPI = `openapi: 3.0.3
+servers:
+  - url: https://api.example.com/{version}
+    variables:
+      version:
+        default: v1
+paths:
+  /pets/{id}:
+    get:
+      responses:
+        "200":
+          description: a pet
+  /pets/mine:
+    get:
+      responses:
+        "200":
+          description: my pet
+          content:
+            application/json:
+              schema:
+                $ref: "#/components/schemas/Pet"
+        2XX:
+          description: accepted
+          content:
+            text/*: {}
+        default:
+          description: an error
+  /{kind}/{id}:
+    get:
+      responses:
+        "200":
+          description: anything
+components:
+  schemas:
+    Pet:
+      type: object
+      required: [id]
+`
+
+func loadTestOpenAPI(t *testing.T) *OpenAPI {
+	path := filepath.Join(t.TempDir(), "openapi.yaml")
+	if err := ioutil.WriteFile(path, []byte(testOpenAPI), 0660); err != nil {
+		t.Fatal(err)
+	}
+	o, err := LoadOpenAPI(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return o
+}
+
+func TestFindOperation(t *testing.T) {
+	o := loadTestOpenAPI(t)
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		want   string
+	}{
+		{"literal", "GET", "/pets/mine", "/pets/mine"},
+		{"literal before parameter", "GET", "/pets/mine/", "/pets/mine"},
+		{"parameter", "GET", "/pets/1", "/pets/{id}"},
+		{"more literal", "GET", "/cats/1", "/{kind}/{id}"},
+		{"server path", "GET", "/v1/pets/mine", "/pets/mine"},
+		{"other server path", "GET", "/v2/pets/mine", ""},
+		{"not found", "GET", "/pets", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			op, err := o.FindOperation(tt.method, tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := ""
+			if op != nil {
+				got = op.Template
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateResponse(t *testing.T) {
+	o := loadTestOpenAPI(t)
+	tests := []struct {
+		name        string
+		path        string
+		status      int
+		contentType string
+		body        string
+		want        []string
+	}{
+		{"exact", "/pets/mine", 200, "application/json", `{"id": 1}`, nil},
+		{"exact invalid", "/pets/mine", 200, "application/json; charset=utf-8", `{}`, []string{"body $: missing required property 'id'"}},
+		{"range", "/pets/mine", 201, "text/plain", `created`, nil},
+		{"range content type", "/pets/mine", 201, "application/json", `{}`, []string{"content type 'application/json' isn't one of text/*"}},
+		{"default", "/pets/mine", 500, "application/json", `oops`, nil},
+		{"undocumented", "/pets/1", 404, "application/json", `{}`, []string{"status 404 isn't a documented response"}},
+		{"no content", "/pets/1", 200, "application/json", `anything`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			op, err := o.FindOperation("GET", tt.path)
+			if err != nil || op == nil {
+				t.Fatalf("finding %v: %v", tt.path, err)
+			}
+			resp := &Response{
+				StatusCode: tt.status,
+				Headers:    map[string]string{"Content-Type": "[" + tt.contentType + "]"},
+				Body:       tt.body,
+			}
+			got := o.ValidateResponse(op, resp)
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchMediaType(t *testing.T) {
+	all := map[string]OpenAPIMediaType{"application/json": {}, "application/*": {}, "*/*": {}}
+	tests := []struct {
+		name        string
+		content     map[string]OpenAPIMediaType
+		contentType string
+		want        string
+	}{
+		{"exact", all, "application/json", "application/json"},
+		{"parameters", all, "Application/JSON; charset=utf-8", "application/json"},
+		{"subtype range", all, "application/xml", "application/*"},
+		{"any", all, "text/html", "*/*"},
+		{"none", map[string]OpenAPIMediaType{"application/json": {}}, "text/html", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, ok := matchMediaType(tt.content, tt.contentType)
+			if got != tt.want || ok != (tt.want != "") {
+				t.Errorf("got %q (%v), want %q", got, ok, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/gookit/color"
+	"gopkg.in/yaml.v3"
+)
+
+// The output modes of the run command.
+const (
+	OutputHuman = "human"
+	OutputJSON  = "json"
+	OutputYAML  = "yaml"
+	OutputRaw   = "raw"
+	OutputQuiet = "quiet"
+)
+
+// RunResult is what's printed for each request when the output is
+// structured.
+type RunResult struct {
+	Name        string    `yaml:"name"`
+	Environment string    `yaml:"environment"`
+	Request     Request   `yaml:"request"`
+	Response    *Response `yaml:"response"`
+
+	// Violations are the ways the response didn't match what was
+	// expected of it (e.g. the OpenAPI document).
+	Violations []string `yaml:"violations,omitempty"`
+}
+
+// redacted is what's written in place of credentials.
+const redacted = "<redacted>"
+
+// redactedHeaders are the headers that have credentials.
+var redactedHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"Set-Cookie":          true,
+}
+
+// redactHeader replaces the values of the headers that have
+// credentials.
+func redactHeader(h http.Header) {
+	for k, vs := range h {
+		if redactedHeaders[http.CanonicalHeaderKey(k)] {
+			for x := range vs {
+				vs[x] = redacted
+			}
+		}
+	}
+}
+
+// redactRequest copies the request without the values of its
+// authentication and credential headers so they aren't written to the
+// output or the history. The type of the authentication is kept.
+func redactRequest(r Request) Request {
+	if len(r.Headers) > 0 {
+		headers := make(map[string]string, len(r.Headers))
+		for k, v := range r.Headers {
+			if redactedHeaders[http.CanonicalHeaderKey(k)] {
+				v = redacted
+			}
+			headers[k] = v
+		}
+		r.Headers = headers
+	}
+	if len(r.Authentication) > 0 {
+		auth := make(map[string]string, len(r.Authentication))
+		for k, v := range r.Authentication {
+			if k != "type" {
+				v = redacted
+			}
+			auth[k] = v
+		}
+		r.Authentication = auth
+	}
+	return r
+}
+
+// startOutput prepares for printing in the given mode. All of the
+// decorated output is discarded unless the mode is human.
+func startOutput(mode string) error {
+	switch mode {
+	case "", OutputHuman:
+	case OutputJSON, OutputYAML, OutputRaw, OutputQuiet:
+		color.SetOutput(ioutil.Discard)
+	default:
+		return fmt.Errorf("unsupported output '%v' (valid: %v, %v, %v, %v, %v)",
+			mode, OutputHuman, OutputJSON, OutputYAML, OutputRaw, OutputQuiet)
+	}
+	return nil
+}
+
+// writeOutput writes the result of a request in the given mode. JSON
+// is written as one object per line and YAML as one document per
+// request.
+func writeOutput(w io.Writer, mode string, result RunResult) error {
+	switch mode {
+	case OutputJSON:
+		buf, err := toJSON(result)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", buf)
+		return err
+	case OutputYAML:
+		buf, err := yaml.Marshal(result)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "---\n%s", buf)
+		return err
+	case OutputRaw:
+		_, err := io.WriteString(w, result.Response.display)
+		return err
+	}
+	return nil
+}
+
+// toJSON marshals v to JSON using the YAML names of its fields so
+// both formats have the same structure.
+func toJSON(v interface{}) ([]byte, error) {
+	buf, err := yaml.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var i interface{}
+	if err := yaml.Unmarshal(buf, &i); err != nil {
+		return nil, err
+	}
+	return json.Marshal(i)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRedactRequest(t *testing.T) {
+	tests := []struct {
+		name string
+		req  Request
+		want Request
+	}{
+		{
+			name: "nothing",
+			req:  Request{URL: "http://example.com"},
+			want: Request{URL: "http://example.com"},
+		},
+		{
+			name: "headers",
+			req: Request{Headers: map[string]string{
+				"authorization":       "Bearer abc",
+				"Proxy-Authorization": "Basic xyz",
+				"Cookie":              "session=abc",
+				"Accept":              "application/json",
+			}},
+			want: Request{Headers: map[string]string{
+				"authorization":       redacted,
+				"Proxy-Authorization": redacted,
+				"Cookie":              redacted,
+				"Accept":              "application/json",
+			}},
+		},
+		{
+			name: "authentication",
+			req:  Request{Authentication: map[string]string{"type": "basic", "username": "me", "password": "secret"}},
+			want: Request{Authentication: map[string]string{"type": "basic", "username": redacted, "password": redacted}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := tt.req.Authentication["password"]
+			got := redactRequest(tt.req)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+			if tt.req.Authentication["password"] != before {
+				t.Errorf("the request being run was changed")
+			}
+		})
+	}
+}
@@ -0,0 +1,572 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/gookit/color"
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// postmanCollection is a Postman (v2.0 or v2.1) collection or
+// environment. Environments only have a name and values.
+type postmanCollection struct {
+	Info     postmanInfo       `json:"info"`
+	Item     []postmanItem     `json:"item"`
+	Auth     *postmanAuth      `json:"auth"`
+	Event    []postmanEvent    `json:"event"`
+	Variable []postmanKeyValue `json:"variable"`
+
+	Name   string            `json:"name"`
+	Values []postmanKeyValue `json:"values"`
+	Scope  string            `json:"_postman_variable_scope"`
+}
+
+type postmanInfo struct {
+	Name   string `json:"name"`
+	Schema string `json:"schema"`
+}
+
+// postmanItem is either a folder (with items) or a request.
+type postmanItem struct {
+	Name                    string            `json:"name"`
+	Item                    []postmanItem     `json:"item"`
+	Request                 json.RawMessage   `json:"request"`
+	Auth                    *postmanAuth      `json:"auth"`
+	Event                   []postmanEvent    `json:"event"`
+	Response                []json.RawMessage `json:"response"`
+	ProtocolProfileBehavior json.RawMessage   `json:"protocolProfileBehavior"`
+}
+
+type postmanRequest struct {
+	Method      string            `json:"method"`
+	URL         json.RawMessage   `json:"url"`
+	Header      []postmanKeyValue `json:"header"`
+	Body        *postmanBody      `json:"body"`
+	Auth        *postmanAuth      `json:"auth"`
+	Description postmanString     `json:"description"`
+	Proxy       json.RawMessage   `json:"proxy"`
+	Certificate json.RawMessage   `json:"certificate"`
+}
+
+type postmanURL struct {
+	Raw      string            `json:"raw"`
+	Query    []postmanKeyValue `json:"query"`
+	Variable []postmanKeyValue `json:"variable"`
+}
+
+type postmanBody struct {
+	Mode       string            `json:"mode"`
+	Raw        string            `json:"raw"`
+	URLEncoded []postmanKeyValue `json:"urlencoded"`
+	FormData   []postmanKeyValue `json:"formdata"`
+	File       struct {
+		Src postmanString `json:"src"`
+	} `json:"file"`
+	GraphQL struct {
+		Query     string `json:"query"`
+		Variables string `json:"variables"`
+	} `json:"graphql"`
+	Options struct {
+		Raw struct {
+			Language string `json:"language"`
+		} `json:"raw"`
+	} `json:"options"`
+	Disabled bool `json:"disabled"`
+}
+
+type postmanKeyValue struct {
+	Key         string          `json:"key"`
+	Value       postmanString   `json:"value"`
+	Disabled    bool            `json:"disabled"`
+	Enabled     *bool           `json:"enabled"`
+	Type        string          `json:"type"`
+	Src         json.RawMessage `json:"src"`
+	ContentType string          `json:"contentType"`
+}
+
+// off is true for values that were turned off in Postman.
+func (kv postmanKeyValue) off() bool {
+	return kv.Disabled || (kv.Enabled != nil && !*kv.Enabled)
+}
+
+type postmanEvent struct {
+	Listen   string `json:"listen"`
+	Disabled bool   `json:"disabled"`
+}
+
+// postmanAuth keeps the parameters of every auth type. They are lists
+// of key/values in v2.1 and objects in v2.0.
+type postmanAuth struct {
+	Type   string
+	params map[string]json.RawMessage
+}
+
+func (a *postmanAuth) UnmarshalJSON(b []byte) error {
+	if err := json.Unmarshal(b, &a.params); err != nil {
+		return err
+	}
+	return json.Unmarshal(a.params["type"], &a.Type)
+}
+
+// Params are the parameters of the auth type.
+func (a *postmanAuth) Params() map[string]string {
+	params := map[string]string{}
+	list := []postmanKeyValue{}
+	if err := json.Unmarshal(a.params[a.Type], &list); err == nil {
+		for _, kv := range list {
+			params[kv.Key] = string(kv.Value)
+		}
+		return params
+	}
+	m := map[string]postmanString{}
+	json.Unmarshal(a.params[a.Type], &m)
+	for k, v := range m {
+		params[k] = string(v)
+	}
+	return params
+}
+
+// postmanString is a string that Postman may also give as a number,
+// boolean or (for descriptions) an object with content.
+type postmanString string
+
+func (s *postmanString) UnmarshalJSON(b []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	switch t := v.(type) {
+	case nil:
+		*s = ""
+	case string:
+		*s = postmanString(t)
+	case map[string]interface{}:
+		*s = postmanString(fmt.Sprintf("%v", t["content"]))
+	case []interface{}:
+		// File sources can be lists; we only support one.
+		if len(t) > 0 {
+			*s = postmanString(fmt.Sprintf("%v", t[0]))
+		}
+	default:
+		*s = postmanString(string(b))
+	}
+	return nil
+}
+
+// postmanImport is the result of converting Postman files.
+type postmanImport struct {
+	// Folders are the requests of each folder (relative to the import
+	// folder) in the order they were found.
+	Folders  []string
+	Requests map[string][]NamedRequest
+
+	Environments map[string]Environment
+	Variables    map[string]string
+	Collection   string
+	Warnings     []string
+}
+
+func newPostmanImport() *postmanImport {
+	return &postmanImport{
+		Requests:     map[string][]NamedRequest{},
+		Environments: map[string]Environment{},
+		Variables:    map[string]string{},
+	}
+}
+
+func (p *postmanImport) warn(where, format string, a ...interface{}) {
+	p.Warnings = append(p.Warnings, where+": "+fmt.Sprintf(format, a...))
+}
+
+// Parse adds the Postman collection or environment in the JSON.
+func (p *postmanImport) Parse(file string, buf []byte) error {
+	pc := postmanCollection{}
+	if err := json.Unmarshal(buf, &pc); err != nil {
+		return fmt.Errorf("parsing %v: %v", file, err)
+	}
+
+	if pc.Values != nil || pc.Scope == "environment" || pc.Scope == "globals" {
+		name := cleanName(pc.Name)
+		if name == "" {
+			name = cleanName(strings.TrimSuffix(filepath.Base(file), filepath.Ext(file)))
+		}
+		env := Environment{}
+		for _, kv := range pc.Values {
+			if kv.off() {
+				p.warn(name, "disabled variable '%v' not imported", kv.Key)
+				continue
+			}
+			env[kv.Key] = p.vars(name, string(kv.Value))
+		}
+		p.Environments[name] = env
+		return nil
+	}
+
+	if pc.Info.Name == "" && pc.Item == nil {
+		return fmt.Errorf("%v isn't a Postman collection or environment", file)
+	}
+	if pc.Info.Schema != "" && !strings.Contains(pc.Info.Schema, "v2.") {
+		p.warn(file, "unknown schema %v; only v2.0 and v2.1 are supported", pc.Info.Schema)
+	}
+	p.Collection = cleanName(pc.Info.Name)
+	p.events(pc.Info.Name, pc.Event)
+	for _, kv := range pc.Variable {
+		if kv.off() {
+			p.warn(pc.Info.Name, "disabled collection variable '%v' not imported", kv.Key)
+			continue
+		}
+		p.Variables[kv.Key] = p.vars(pc.Info.Name, string(kv.Value))
+	}
+	p.items("", pc.Item, pc.Auth)
+	return nil
+}
+
+// items adds the requests in the folder and its sub folders. Requests
+// without auth inherit it from their folder.
+func (p *postmanImport) items(folder string, items []postmanItem, auth *postmanAuth) {
+	for _, item := range items {
+		where := strings.TrimPrefix(folder+"/"+item.Name, "/")
+		if item.Request == nil {
+			sub := strings.TrimPrefix(folder+"/"+cleanName(item.Name), "/")
+			p.events(where, item.Event)
+			inherited := auth
+			if item.Auth != nil {
+				inherited = item.Auth
+			}
+			p.items(sub, item.Item, inherited)
+			continue
+		}
+
+		pr := postmanRequest{}
+		if bytes.HasPrefix(bytes.TrimSpace(item.Request), []byte(`"`)) {
+			// A request can be just its URL.
+			pr.Method, pr.URL = "GET", item.Request
+		} else if err := json.Unmarshal(item.Request, &pr); err != nil {
+			p.warn(where, "not imported: %v", err)
+			continue
+		}
+		if pr.Auth == nil {
+			pr.Auth = auth
+		}
+
+		r := p.request(where, pr)
+		p.events(where, item.Event)
+		if len(item.Response) > 0 {
+			p.warn(where, "%v saved example responses not imported", len(item.Response))
+		}
+		if len(item.ProtocolProfileBehavior) > 0 && string(item.ProtocolProfileBehavior) != "{}" {
+			p.warn(where, "protocol profile behavior (%s) not imported", bytes.Join(bytes.Fields(item.ProtocolProfileBehavior), nil))
+		}
+
+		name := cleanName(item.Name)
+		if name == "" {
+			name = requestName(r.Method, r.URL)
+		}
+		if _, ok := p.Requests[folder]; !ok {
+			p.Folders = append(p.Folders, folder)
+		}
+		p.Requests[folder] = append(p.Requests[folder], NamedRequest{Name: name, Request: r})
+	}
+}
+
+// events reports the scripts, which can't be converted.
+func (p *postmanImport) events(where string, events []postmanEvent) {
+	for _, e := range events {
+		if !e.Disabled {
+			p.warn(where, "%v script not imported", e.Listen)
+		}
+	}
+}
+
+func (p *postmanImport) request(where string, pr postmanRequest) Request {
+	r := Request{
+		Method:         strings.ToUpper(pr.Method),
+		Description:    string(pr.Description),
+		Headers:        map[string]string{},
+		Authentication: map[string]string{},
+		Query:          map[string]string{},
+	}
+	if r.Method == "" {
+		r.Method = "GET"
+	}
+
+	// The URL is a string or an object with its parts.
+	pu := postmanURL{}
+	if err := json.Unmarshal(pr.URL, &pu.Raw); err != nil {
+		json.Unmarshal(pr.URL, &pu)
+	}
+	raw := pu.Raw
+	query := ""
+	if i := strings.Index(raw, "?"); i >= 0 {
+		raw, query = raw[:i], raw[i+1:]
+	}
+	raw = strings.SplitN(raw, "#", 2)[0]
+	for _, v := range pu.Variable {
+		if v.Value == "" {
+			p.warn(where, "path variable ':%v' has no value", v.Key)
+			continue
+		}
+		raw = pathVariable(raw, v.Key, string(v.Value))
+	}
+	if !strings.Contains(raw, "://") && !strings.HasPrefix(raw, "{{") {
+		// Postman defaults to http.
+		raw = "http://" + raw
+	}
+	r.URL = p.vars(where, raw)
+
+	if pu.Query != nil {
+		for _, kv := range pu.Query {
+			p.set(where, "query parameter", r.Query, kv)
+		}
+	} else if query != "" {
+		for _, q := range strings.Split(query, "&") {
+			kv := strings.SplitN(q, "=", 2)
+			if len(kv) == 1 {
+				kv = append(kv, "")
+			}
+			p.set(where, "query parameter", r.Query, postmanKeyValue{Key: unescape(kv[0]), Value: postmanString(unescape(kv[1]))})
+		}
+	}
+
+	for _, kv := range pr.Header {
+		p.set(where, "header", r.Headers, kv)
+	}
+
+	p.auth(where, &r, pr.Auth)
+	if pr.Body != nil && !pr.Body.Disabled {
+		p.body(where, &r, pr.Body)
+	}
+	if len(pr.Proxy) > 0 {
+		p.warn(where, "proxy not imported (use the proxy preferences)")
+	}
+	if len(pr.Certificate) > 0 {
+		p.warn(where, "certificate not imported (use tls in the request)")
+	}
+	return r
+}
+
+// set adds the key/value to the map if it's enabled. Disabled and
+// repeated keys are reported since they can't be kept.
+func (p *postmanImport) set(where, what string, m map[string]string, kv postmanKeyValue) {
+	if kv.off() {
+		p.warn(where, "disabled %v '%v' not imported", what, kv.Key)
+		return
+	}
+	k := p.vars(where, kv.Key)
+	if _, ok := m[k]; ok {
+		p.warn(where, "repeated %v '%v'; only the last value is kept", what, kv.Key)
+	}
+	m[k] = p.vars(where, string(kv.Value))
+}
+
+func (p *postmanImport) auth(where string, r *Request, auth *postmanAuth) {
+	if auth == nil {
+		return
+	}
+	params := auth.Params()
+	switch auth.Type {
+	case "noauth", "":
+	case "bearer":
+		r.Authentication["type"] = "bearer"
+		r.Authentication["token"] = p.vars(where, params["token"])
+	case "basic":
+		r.Authentication["type"] = "basic"
+		r.Authentication["username"] = p.vars(where, params["username"])
+		r.Authentication["password"] = p.vars(where, params["password"])
+	case "apikey":
+		kv := postmanKeyValue{Key: params["key"], Value: postmanString(params["value"])}
+		if params["in"] == "query" {
+			p.set(where, "query parameter", r.Query, kv)
+		} else {
+			p.set(where, "header", r.Headers, kv)
+		}
+	case "oauth2":
+		if token := params["accessToken"]; token != "" {
+			r.Authentication["type"] = "bearer"
+			r.Authentication["token"] = p.vars(where, token)
+			p.warn(where, "oauth2 imported as its current access token; getting new tokens isn't supported")
+		} else {
+			p.warn(where, "oauth2 authentication not imported")
+		}
+	default:
+		p.warn(where, "%v authentication not imported", auth.Type)
+	}
+}
+
+// postmanLanguages are the content types Postman sends for raw bodies.
+var postmanLanguages = map[string]string{
+	"json":       "application/json",
+	"xml":        "application/xml",
+	"html":       "text/html",
+	"javascript": "application/javascript",
+	"text":       "text/plain",
+}
+
+func (p *postmanImport) body(where string, r *Request, b *postmanBody) {
+	switch b.Mode {
+	case "raw":
+		if b.Raw == "" {
+			return
+		}
+		r.Body = Body{Type: "raw", Value: p.vars(where, b.Raw)}
+		if ct, ok := postmanLanguages[b.Options.Raw.Language]; ok {
+			setDefault(r.Headers, "Content-Type", ct)
+		}
+	case "urlencoded":
+		data := []string{}
+		for _, kv := range b.URLEncoded {
+			if kv.off() {
+				p.warn(where, "disabled form field '%v' not imported", kv.Key)
+				continue
+			}
+			data = append(data, escapeVars(p.vars(where, kv.Key))+"="+escapeVars(p.vars(where, string(kv.Value))))
+		}
+		r.Body = Body{Type: "raw", Value: strings.Join(data, "&")}
+		setDefault(r.Headers, "Content-Type", "application/x-www-form-urlencoded")
+	case "formdata":
+		parts := []MultiPartPart{}
+		for _, kv := range b.FormData {
+			if kv.off() {
+				p.warn(where, "disabled form field '%v' not imported", kv.Key)
+				continue
+			}
+			part := MultiPartPart{Type: "raw", Name: p.vars(where, kv.Key), Value: p.vars(where, string(kv.Value))}
+			if kv.Type == "file" {
+				src := postmanString("")
+				json.Unmarshal(kv.Src, &src)
+				if strings.HasPrefix(strings.TrimSpace(string(kv.Src)), "[") && strings.Contains(string(kv.Src), ",") {
+					p.warn(where, "form field '%v' has several files; only the first is imported", kv.Key)
+				}
+				part.Type, part.Value = "file", p.vars(where, string(src))
+			}
+			if kv.ContentType != "" {
+				p.warn(where, "content type of form field '%v' not imported", kv.Key)
+			}
+			parts = append(parts, part)
+		}
+		buf, err := yaml.Marshal(parts)
+		if err != nil {
+			p.warn(where, "form not imported: %v", err)
+			return
+		}
+		r.Body = Body{Type: "multipart", Value: string(buf)}
+	case "file":
+		r.Body = Body{Type: "file", Value: p.vars(where, string(b.File.Src))}
+	case "graphql":
+		// Sent as the JSON Postman would send.
+		variables := strings.TrimSpace(b.GraphQL.Variables)
+		if variables == "" {
+			variables = "{}"
+		}
+		if !json.Valid([]byte(variables)) {
+			p.warn(where, "graphql variables aren't valid JSON; they weren't imported")
+			variables = "{}"
+		}
+		query := quoteJSON(b.GraphQL.Query)
+		r.Body = Body{Type: "raw", Value: p.vars(where, fmt.Sprintf(`{"query":%s,"variables":%s}`, query, variables))}
+		setDefault(r.Headers, "Content-Type", "application/json")
+	case "":
+	default:
+		p.warn(where, "%v body not imported", b.Mode)
+	}
+}
+
+var postmanVariable = regexp.MustCompile(`\{\{\s*([^{}]+?)\s*\}\}`)
+
+// vars changes Postman variables ({{name}}) into environment
+// variables. Dynamic variables ({{$guid}}) are reported and left
+// alone.
+func (p *postmanImport) vars(where, s string) string {
+	return postmanVariable.ReplaceAllStringFunc(s, func(m string) string {
+		name := postmanVariable.FindStringSubmatch(m)[1]
+		if strings.HasPrefix(name, "$") {
+			p.warn(where, "dynamic variable '%v' not supported", name)
+			return m
+		}
+		return "{{environment." + name + "}}"
+	})
+}
+
+// pathVariable replaces the :name path variable in the URL.
+func pathVariable(u, name, value string) string {
+	parts := strings.Split(u, "/")
+	for x, part := range parts {
+		if part == ":"+name {
+			parts[x] = value
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// escapeVars URL encodes the value but not the variables in it so they
+// can still be interpolated.
+func escapeVars(s string) string {
+	out := &strings.Builder{}
+	last := 0
+	for _, m := range re.FindAllStringIndex(s, -1) {
+		out.WriteString(url.QueryEscape(s[last:m[0]]))
+		out.WriteString(s[m[0]:m[1]])
+		last = m[1]
+	}
+	out.WriteString(url.QueryEscape(s[last:]))
+	return out.String()
+}
+
+func unescape(s string) string {
+	if u, err := url.QueryUnescape(s); err == nil {
+		return u
+	}
+	return s
+}
+
+// cleanName makes a Postman name usable as a request name or folder.
+func cleanName(s string) string {
+	return strings.Trim(nameCleaner.ReplaceAllString(strings.ToLower(s), "-"), "-")
+}
+
+func importpostman(c *cli.Context) error {
+	if !c.Args().Present() {
+		return cli.Exit(color.Red.Sprintf("import postman expects collection or environment files"), -1)
+	}
+
+	p := newPostmanImport()
+	for _, file := range c.Args().Slice() {
+		buf, err := ioutil.ReadFile(file)
+		if err != nil {
+			return cli.Exit(color.Red.Sprintf("reading %v: %v", file, err), -1)
+		}
+		if err := p.Parse(file, buf); err != nil {
+			return cli.Exit(color.Red.Sprintf("%v", err), -1)
+		}
+	}
+
+	// Collection variables don't exist in aa, so they are added to the
+	// environments unless they set them already.
+	if len(p.Variables) > 0 {
+		if len(p.Environments) == 0 {
+			name := p.Collection
+			if name == "" {
+				name = "postman"
+			}
+			p.Environments[name] = Environment{}
+			p.Warnings = append(p.Warnings, fmt.Sprintf("collection variables imported as the '%v' environment", name))
+		}
+		for _, env := range p.Environments {
+			for k, v := range p.Variables {
+				if _, ok := env[k]; !ok {
+					env[k] = v
+				}
+			}
+		}
+	}
+
+	return writeImport(c, p.Folders, p.Requests, p.Environments, p.Warnings)
+}
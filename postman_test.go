@@ -0,0 +1,216 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParsePostman(t *testing.T) {
+	v21 := `{
+  "info": {"name": "Shop", "schema": "https://schema.getpostman.com/json/collection/v2.1.0/collection.json"},
+  "auth": {"type": "bearer", "bearer": [{"key": "token", "value": "{{token}}", "type": "string"}]},
+  "event": [{"listen": "prerequest", "script": {"exec": ["console.log(1)"]}}],
+  "variable": [{"key": "host", "value": "shop.test"}, {"key": "old", "value": "x", "disabled": true}],
+  "item": [
+    {
+      "name": "Users",
+      "item": [
+        {
+          "name": "Get User",
+          "request": {
+            "method": "get",
+            "url": {
+              "raw": "{{host}}/users/:id?expand=true",
+              "query": [{"key": "expand", "value": "true"}, {"key": "debug", "value": "1", "disabled": true}],
+              "variable": [{"key": "id", "value": "{{userId}}"}]
+            },
+            "header": [{"key": "Accept", "value": "application/json"}, {"key": "X-Id", "value": "{{$guid}}"}]
+          },
+          "response": [{"name": "example"}]
+        }
+      ]
+    },
+    {
+      "name": "Login",
+      "request": {
+        "method": "POST",
+        "url": "https://{{host}}/login",
+        "auth": {"type": "basic", "basic": [{"key": "username", "value": "me"}, {"key": "password", "value": "{{password}}"}]},
+        "body": {"mode": "raw", "raw": "{\"remember\": true}", "options": {"raw": {"language": "json"}}},
+        "proxy": {"host": "proxy.test"}
+      },
+      "protocolProfileBehavior": {"followRedirects": false}
+    },
+    {
+      "name": "Upload",
+      "request": {
+        "method": "POST",
+        "url": "https://{{host}}/upload",
+        "auth": {"type": "hawk", "hawk": []},
+        "body": {"mode": "formdata", "formdata": [
+          {"key": "name", "value": "a", "type": "text"},
+          {"key": "file", "src": "/tmp/a.txt", "type": "file", "contentType": "text/plain"},
+          {"key": "off", "value": "b", "type": "text", "disabled": true}
+        ]}
+      }
+    }
+  ]
+}`
+	v20 := `{
+  "info": {"name": "Legacy", "schema": "https://schema.getpostman.com/json/collection/v2.0.0/collection.json"},
+  "item": [
+    {
+      "name": "Search",
+      "request": {
+        "method": "POST",
+        "url": "http://legacy.test/search?q=a%20b&q=c",
+        "auth": {"type": "basic", "basic": {"username": "me", "password": "secret"}},
+        "body": {"mode": "urlencoded", "urlencoded": [{"key": "term", "value": "a&b {{x}}"}]}
+      }
+    },
+    {"name": "Ping", "request": "legacy.test/ping"},
+    {"name": "Soap", "request": {"method": "POST", "url": "http://legacy.test/soap", "body": {"mode": "binary"}}}
+  ]
+}`
+	env := `{"name": "Dev Env", "_postman_variable_scope": "environment", "values": [
+  {"key": "host", "value": "dev.test", "enabled": true},
+  {"key": "token", "value": "abc", "enabled": false}
+]}`
+
+	tests := []struct {
+		name     string
+		file     string
+		body     string
+		requests map[string]map[string]Request
+		warnings []string
+	}{
+		{
+			name: "v2.1",
+			file: "shop.json",
+			body: v21,
+			requests: map[string]map[string]Request{
+				"users": {"get-user": {
+					Method:         "GET",
+					URL:            "{{environment.host}}/users/{{environment.userId}}",
+					Headers:        map[string]string{"Accept": "application/json", "X-Id": "{{$guid}}"},
+					Authentication: map[string]string{"type": "bearer", "token": "{{environment.token}}"},
+					Query:          map[string]string{"expand": "true"},
+				}},
+				"": {
+					"login": {
+						Method:         "POST",
+						URL:            "https://{{environment.host}}/login",
+						Headers:        map[string]string{"Content-Type": "application/json"},
+						Authentication: map[string]string{"type": "basic", "username": "me", "password": "{{environment.password}}"},
+						Query:          map[string]string{},
+						Body:           Body{Type: "raw", Value: `{"remember": true}`},
+					},
+					"upload": {
+						Method:         "POST",
+						URL:            "https://{{environment.host}}/upload",
+						Headers:        map[string]string{},
+						Authentication: map[string]string{},
+						Query:          map[string]string{},
+						Body:           Body{Type: "multipart", Value: "- type: raw\n  name: name\n  value: a\n- type: file\n  name: file\n  value: /tmp/a.txt\n"},
+					},
+				},
+			},
+			warnings: []string{
+				"Shop: prerequest script not imported",
+				"Shop: disabled collection variable 'old' not imported",
+				"users/Get User: disabled query parameter 'debug' not imported",
+				"users/Get User: dynamic variable '$guid' not supported",
+				"users/Get User: 1 saved example responses not imported",
+				"Login: proxy not imported (use the proxy preferences)",
+				"Login: protocol profile behavior ({\"followRedirects\":false}) not imported",
+				"Upload: hawk authentication not imported",
+				"Upload: content type of form field 'file' not imported",
+				"Upload: disabled form field 'off' not imported",
+			},
+		},
+		{
+			name: "v2.0",
+			file: "legacy.json",
+			body: v20,
+			requests: map[string]map[string]Request{
+				"": {
+					"search": {
+						Method:         "POST",
+						URL:            "http://legacy.test/search",
+						Headers:        map[string]string{"Content-Type": "application/x-www-form-urlencoded"},
+						Authentication: map[string]string{"type": "basic", "username": "me", "password": "secret"},
+						Query:          map[string]string{"q": "c"},
+						Body:           Body{Type: "raw", Value: "term=a%26b+{{environment.x}}"},
+					},
+					"ping": {
+						Method:         "GET",
+						URL:            "http://legacy.test/ping",
+						Headers:        map[string]string{},
+						Authentication: map[string]string{},
+						Query:          map[string]string{},
+					},
+					"soap": {
+						Method:         "POST",
+						URL:            "http://legacy.test/soap",
+						Headers:        map[string]string{},
+						Authentication: map[string]string{},
+						Query:          map[string]string{},
+					},
+				},
+			},
+			warnings: []string{
+				"Search: repeated query parameter 'q'; only the last value is kept",
+				"Soap: binary body not imported",
+			},
+		},
+		{
+			name:     "unknown schema",
+			file:     "new.json",
+			body:     `{"info": {"name": "New", "schema": "https://schema.getpostman.com/json/collection/v3.0.0/"}, "item": []}`,
+			requests: map[string]map[string]Request{},
+			warnings: []string{"new.json: unknown schema https://schema.getpostman.com/json/collection/v3.0.0/; only v2.0 and v2.1 are supported"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newPostmanImport()
+			if err := p.Parse(tt.file, []byte(tt.body)); err != nil {
+				t.Fatal(err)
+			}
+			got := map[string]map[string]Request{}
+			for folder, requests := range p.Requests {
+				got[folder] = map[string]Request{}
+				for _, r := range requests {
+					got[folder][r.Name] = r.Request
+				}
+			}
+			if !reflect.DeepEqual(got, tt.requests) {
+				t.Errorf("got requests\n%+v\nwant\n%+v", got, tt.requests)
+			}
+			if strings.Join(p.Warnings, "\n") != strings.Join(tt.warnings, "\n") {
+				t.Errorf("got warnings\n%v\nwant\n%v", strings.Join(p.Warnings, "\n"), strings.Join(tt.warnings, "\n"))
+			}
+		})
+	}
+
+	t.Run("environment", func(t *testing.T) {
+		p := newPostmanImport()
+		if err := p.Parse("dev.postman_environment.json", []byte(env)); err != nil {
+			t.Fatal(err)
+		}
+		want := map[string]Environment{"dev-env": {"host": "dev.test"}}
+		if !reflect.DeepEqual(p.Environments, want) {
+			t.Errorf("got %v, want %v", p.Environments, want)
+		}
+		if w := strings.Join(p.Warnings, "\n"); w != "dev-env: disabled variable 'token' not imported" {
+			t.Errorf("got warnings %q", w)
+		}
+	})
+
+	t.Run("not postman", func(t *testing.T) {
+		if err := newPostmanImport().Parse("other.json", []byte(`{"a": 1}`)); err == nil || err.Error() != "other.json isn't a Postman collection or environment" {
+			t.Errorf("got error %v", err)
+		}
+	})
+}
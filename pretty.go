@@ -0,0 +1,389 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/xml"
+	"io"
+	"mime"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gookit/color"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/net/html"
+	"gopkg.in/yaml.v3"
+)
+
+// MaxHexDump is the most bytes of a binary body we'll show.
+const MaxHexDump = 4096
+
+// Palette colors the parts of a pretty printed body.
+type Palette struct {
+	Key     func(string) string
+	String  func(string) string
+	Number  func(string) string
+	Literal func(string) string
+	Tag     func(string) string
+	Attr    func(string) string
+	Comment func(string) string
+}
+
+func plain(s string) string { return s }
+
+// PlainPalette doesn't color anything.
+var PlainPalette = Palette{plain, plain, plain, plain, plain, plain, plain}
+
+// ColorPalette highlights bodies using colors that fit in with the
+// rest of the output.
+var ColorPalette = Palette{
+	Key:     func(s string) string { return color.Cyan.Sprint(s) },
+	String:  func(s string) string { return color.Green.Sprint(s) },
+	Number:  func(s string) string { return color.Yellow.Sprint(s) },
+	Literal: func(s string) string { return color.Magenta.Sprint(s) },
+	Tag:     func(s string) string { return color.Blue.Sprint(s) },
+	Attr:    func(s string) string { return color.Cyan.Sprint(s) },
+	Comment: func(s string) string { return color.Gray.Sprint(s) },
+}
+
+// isJSONType determines if the media type is JSON.
+func isJSONType(mt string) bool {
+	return mt == "application/json" || strings.HasSuffix(mt, "+json")
+}
+
+// prettyBody formats the body based on its content type. The body is
+// returned unchanged if we don't know how to format it or it can't
+// be parsed.
+func prettyBody(contentType string, body []byte, indent string, p Palette) []byte {
+	mt, _, _ := mime.ParseMediaType(contentType)
+
+	var out []byte
+	var err error
+	switch {
+	case mt == "application/x-ndjson" || mt == "application/jsonl" || mt == "application/x-jsonlines":
+		out, err = prettyNDJSON(body, indent, p)
+	case isJSONType(mt):
+		out, err = prettyJSON(body, indent, p)
+	case mt == "application/xml" || mt == "text/xml" || strings.HasSuffix(mt, "+xml"):
+		out, err = prettyXML(body, indent, p)
+	case mt == "text/html":
+		out, err = prettyHTML(body, indent, p)
+	case mt == "application/x-www-form-urlencoded":
+		out, err = prettyForm(body, p)
+	case strings.HasSuffix(mt, "yaml"):
+		out, err = prettyYAML(body, len(indent), p)
+	default:
+		return body
+	}
+	if err != nil {
+		return body
+	}
+	return out
+}
+
+func prettyJSON(body []byte, indent string, p Palette) ([]byte, error) {
+	v, err := decodeJSON(body)
+	if err != nil {
+		return nil, err
+	}
+	return encodeJSON(v, indent, p), nil
+}
+
+func prettyNDJSON(body []byte, indent string, p Palette) ([]byte, error) {
+	out := &bytes.Buffer{}
+	for _, line := range strings.Split(strings.TrimSpace(string(body)), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		buf, err := prettyJSON([]byte(line), indent, p)
+		if err != nil {
+			return nil, err
+		}
+		out.Write(buf)
+		out.WriteString("\n")
+	}
+	return bytes.TrimRight(out.Bytes(), "\n"), nil
+}
+
+// xmlText escapes the text of XML elements. Unlike xml.EscapeText it
+// keeps the newlines, tabs and quotes, which don't need to be escaped.
+var xmlText = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// prettyXML indents XML. We use the raw tokens so namespace prefixes
+// are written as they were given.
+func prettyXML(body []byte, indent string, p Palette) ([]byte, error) {
+	d := xml.NewDecoder(bytes.NewReader(body))
+	d.Strict = false
+	out := &bytes.Buffer{}
+	depth := 0
+	inline := false // the last thing written was a start tag or text
+
+	name := func(n xml.Name) string {
+		if n.Space != "" {
+			return n.Space + ":" + n.Local
+		}
+		return n.Local
+	}
+	newline := func() {
+		if out.Len() > 0 {
+			out.WriteString("\n")
+		}
+		out.WriteString(strings.Repeat(indent, depth))
+	}
+
+	for {
+		tok, err := d.RawToken()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			newline()
+			out.WriteString(p.Tag("<" + name(t.Name)))
+			for _, a := range t.Attr {
+				buf := &bytes.Buffer{}
+				xml.EscapeText(buf, []byte(a.Value))
+				out.WriteString(" " + p.Attr(name(a.Name)) + "=" + p.String(`"`+buf.String()+`"`))
+			}
+			out.WriteString(p.Tag(">"))
+			depth++
+			inline = true
+		case xml.EndElement:
+			depth--
+			if !inline {
+				newline()
+			}
+			out.WriteString(p.Tag("</" + name(t.Name) + ">"))
+			inline = false
+		case xml.CharData:
+			s := strings.TrimSpace(string(t))
+			if s == "" {
+				continue
+			}
+			if !inline {
+				newline()
+			}
+			out.WriteString(xmlText.Replace(s))
+		case xml.Comment:
+			newline()
+			out.WriteString(p.Comment("<!--" + string(t) + "-->"))
+			inline = false
+		case xml.ProcInst:
+			newline()
+			out.WriteString(p.Comment("<?" + t.Target + " " + string(t.Inst) + "?>"))
+			inline = false
+		case xml.Directive:
+			newline()
+			out.WriteString(p.Comment("<!" + string(t) + ">"))
+			inline = false
+		}
+	}
+	return out.Bytes(), nil
+}
+
+// voidElements are the HTML elements that don't have an end tag.
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true,
+	"hr": true, "img": true, "input": true, "link": true, "meta": true,
+	"param": true, "source": true, "track": true, "wbr": true,
+}
+
+func prettyHTML(body []byte, indent string, p Palette) ([]byte, error) {
+	z := html.NewTokenizer(bytes.NewReader(body))
+	var toks []html.Token
+	for {
+		if z.Next() == html.ErrorToken {
+			if z.Err() != io.EOF {
+				return nil, z.Err()
+			}
+			break
+		}
+		toks = append(toks, z.Token())
+	}
+
+	// Only elements that have an end tag are indented. Elements left
+	// open (e.g. <p> or <li>) are closed by the end tag of their
+	// parent, so they don't change the depth.
+	closed := map[int]bool{}  // start tags with a matching end tag
+	matched := map[int]bool{} // end tags with a matching start tag
+	var open []int
+	for x, tok := range toks {
+		switch {
+		case tok.Type == html.StartTagToken && !voidElements[tok.Data]:
+			open = append(open, x)
+		case tok.Type == html.EndTagToken:
+			for y := len(open) - 1; y >= 0; y-- {
+				if toks[open[y]].Data == tok.Data {
+					closed[open[y]], matched[x] = true, true
+					open = open[:y]
+					break
+				}
+			}
+		}
+	}
+
+	out := &bytes.Buffer{}
+	depth := 0
+	raw := false // inside of script or style
+	newline := func() {
+		if out.Len() > 0 {
+			out.WriteString("\n")
+		}
+		out.WriteString(strings.Repeat(indent, depth))
+	}
+
+	for x, tok := range toks {
+		switch tok.Type {
+		case html.StartTagToken, html.SelfClosingTagToken:
+			newline()
+			out.WriteString(p.Tag("<" + tok.Data))
+			for _, a := range tok.Attr {
+				out.WriteString(" " + p.Attr(a.Key) + "=" + p.String(`"`+html.EscapeString(a.Val)+`"`))
+			}
+			if tok.Type == html.SelfClosingTagToken {
+				out.WriteString(p.Tag(" />"))
+			} else {
+				out.WriteString(p.Tag(">"))
+				if closed[x] {
+					depth++
+				}
+				raw = tok.Data == "script" || tok.Data == "style"
+			}
+		case html.EndTagToken:
+			if matched[x] {
+				depth--
+			}
+			newline()
+			out.WriteString(p.Tag("</" + tok.Data + ">"))
+			raw = false
+		case html.TextToken:
+			s := strings.TrimSpace(tok.Data)
+			if s == "" {
+				continue
+			}
+			if raw {
+				newline()
+				out.WriteString(s)
+				continue
+			}
+			newline()
+			out.WriteString(html.EscapeString(strings.Join(strings.Fields(s), " ")))
+		case html.CommentToken:
+			newline()
+			out.WriteString(p.Comment("<!--" + tok.Data + "-->"))
+		case html.DoctypeToken:
+			newline()
+			out.WriteString(p.Comment("<!DOCTYPE " + tok.Data + ">"))
+		}
+	}
+	return out.Bytes(), nil
+}
+
+// prettyForm writes each form value on its own line in the order
+// they were given.
+func prettyForm(body []byte, p Palette) ([]byte, error) {
+	out := &bytes.Buffer{}
+	for _, pair := range strings.Split(strings.TrimSpace(string(body)), "&") {
+		if pair == "" {
+			continue
+		}
+		kv := strings.SplitN(pair, "=", 2)
+		k, err := url.QueryUnescape(kv[0])
+		if err != nil {
+			return nil, err
+		}
+		v := ""
+		if len(kv) == 2 {
+			if v, err = url.QueryUnescape(kv[1]); err != nil {
+				return nil, err
+			}
+		}
+		if out.Len() > 0 {
+			out.WriteString("\n")
+		}
+		out.WriteString(p.Key(k) + " = " + p.String(v))
+	}
+	return out.Bytes(), nil
+}
+
+var yamlKey = regexp.MustCompile(`^(\s*(?:- )*)([^\s#'"][^:#]*|"[^"]*"|'[^']*'):(\s|$)`)
+
+func prettyYAML(body []byte, indent int, p Palette) ([]byte, error) {
+	n := &yaml.Node{}
+	if err := yaml.Unmarshal(body, n); err != nil {
+		return nil, err
+	}
+	buf := &bytes.Buffer{}
+	e := yaml.NewEncoder(buf)
+	if indent > 0 {
+		e.SetIndent(indent)
+	}
+	if err := e.Encode(n); err != nil {
+		return nil, err
+	}
+	e.Close()
+
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	for x, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			lines[x] = p.Comment(line)
+		} else if m := yamlKey.FindStringSubmatchIndex(line); m != nil {
+			lines[x] = line[:m[4]] + p.Key(line[m[4]:m[5]]) + line[m[5]:]
+		}
+	}
+	return []byte(strings.Join(lines, "\n")), nil
+}
+
+// isBinary guesses if the body shouldn't be printed to a terminal.
+func isBinary(body []byte) bool {
+	if !utf8.Valid(body) {
+		return true
+	}
+	for _, b := range body {
+		if b < 0x20 && b != '\n' && b != '\r' && b != '\t' && b != '\f' {
+			return true
+		}
+	}
+	return false
+}
+
+// printBody prints a body in the human output. The display is the
+// body after formatting. Binary bodies are hex dumped and pretty
+// printed bodies are highlighted.
+func printBody(ctx *cli.Context, contentType string, body, display []byte) {
+	if isBinary(display) {
+		dump := display
+		if len(dump) > MaxHexDump {
+			dump = dump[:MaxHexDump]
+		}
+		color.Green.Printf("%s", hex.Dump(dump))
+		if len(display) > len(dump) {
+			color.Green.Printf("<%v more bytes>\n", len(display)-len(dump))
+		}
+		return
+	}
+
+	if !ctx.Bool("pretty") {
+		color.Green.Printf("%s\n", display)
+		return
+	}
+
+	// The display of JSON (or anything filtered) is JSON we wrote, so
+	// we can highlight it. Filters only apply to JSON, so we format
+	// anything else again with colors.
+	mt, _, _ := mime.ParseMediaType(contentType)
+	if isJSONType(mt) || ctx.String("filter") != "" {
+		if v, err := decodeJSON(display); err == nil {
+			color.Normal.Printf("%s\n", encodeJSON(v, indentString(ctx), ColorPalette))
+			return
+		}
+		color.Green.Printf("%s\n", display)
+		return
+	}
+	color.Normal.Printf("%s\n", prettyBody(contentType, body, indentString(ctx), ColorPalette))
+}
@@ -0,0 +1,54 @@
+package main
+
+import "testing"
+
+func TestPrettyMarkup(t *testing.T) {
+	tests := []struct {
+		name   string
+		pretty func([]byte, string, Palette) ([]byte, error)
+		body   string
+		want   string
+	}{
+		{
+			name:   "xml attribute",
+			pretty: prettyXML,
+			body:   `<a b="x &lt; &quot;y&quot; é"/>`,
+			want:   "<a b=\"x &lt; &#34;y&#34; é\"></a>",
+		},
+		{
+			name:   "xml text",
+			pretty: prettyXML,
+			body:   "<a>line 1\n\t\"line\" 2 &amp; &lt;3&gt;</a>",
+			want:   "<a>line 1\n\t\"line\" 2 &amp; &lt;3&gt;</a>",
+		},
+		{
+			name:   "html attribute",
+			pretty: prettyHTML,
+			body:   `<a title="&quot;é&quot; &amp;">x</a>`,
+			want:   "<a title=\"&#34;é&#34; &amp;\">\n  x\n</a>",
+		},
+		{
+			name:   "html void and unclosed",
+			pretty: prettyHTML,
+			body:   `<ul><li>a<li>b<br></ul><p>c`,
+			want:   "<ul>\n  <li>\n  a\n  <li>\n  b\n  <br>\n</ul>\n<p>\nc",
+		},
+		{
+			name:   "html stray end tag",
+			pretty: prettyHTML,
+			body:   `<div></span>x</div>`,
+			want:   "<div>\n  </span>\n  x\n</div>",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.pretty([]byte(tt.body), "  ", PlainPalette)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
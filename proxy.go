@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ProxyConfig is the explicit proxy configuration for an
+// environment. It's read from the proxy key of the environment:
+//
+//	proxy:
+//	  http: http://proxy.example.com:3128
+//	  https: http://proxy.example.com:3128
+//	  socks5: socks5://bastion.example.com:1080
+//	  username: me
+//	  password: secret
+//	  no-proxy: localhost,.internal.example.com,10.0.0.0/8
+type ProxyConfig struct {
+	HTTP     *url.URL
+	HTTPS    *url.URL
+	SOCKS5   *url.URL
+	Username string
+	Password string
+	NoProxy  []string
+}
+
+// NewProxyConfig creates the proxy configuration from the flattened
+// interpolation variables. If the environment doesn't define any
+// proxies, nil is returned.
+func NewProxyConfig(vars map[string]string) (*ProxyConfig, error) {
+	p := &ProxyConfig{
+		Username: vars["environment.proxy.username"],
+		Password: vars["environment.proxy.password"],
+	}
+
+	for _, np := range strings.Split(vars["environment.proxy.no-proxy"], ",") {
+		if np = strings.TrimSpace(np); np != "" {
+			p.NoProxy = append(p.NoProxy, strings.ToLower(np))
+		}
+	}
+
+	var err error
+	if p.HTTP, err = parseProxyURL(vars["environment.proxy.http"], "http"); err != nil {
+		return nil, err
+	}
+	if p.HTTPS, err = parseProxyURL(vars["environment.proxy.https"], "http"); err != nil {
+		return nil, err
+	}
+	if p.SOCKS5, err = parseProxyURL(vars["environment.proxy.socks5"], "socks5"); err != nil {
+		return nil, err
+	}
+
+	if p.HTTP == nil && p.HTTPS == nil && p.SOCKS5 == nil {
+		return nil, nil
+	}
+	return p, nil
+}
+
+func parseProxyURL(s, scheme string) (*url.URL, error) {
+	if s == "" {
+		return nil, nil
+	}
+	if !strings.Contains(s, "://") {
+		s = scheme + "://" + s
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("parsing proxy url '%v': %v", s, err)
+	}
+	return u, nil
+}
+
+// Proxy returns the proxy to use for the given request. It can be
+// used as the Proxy of an http.Transport. The SOCKS5 proxy is used
+// for all requests if it's set, otherwise the proxy for the scheme
+// of the request is used.
+func (p *ProxyConfig) Proxy(req *http.Request) (*url.URL, error) {
+	if p.bypass(req.URL) {
+		return nil, nil
+	}
+
+	u := p.SOCKS5
+	if u == nil && req.URL.Scheme == "https" {
+		u = p.HTTPS
+	} else if u == nil {
+		u = p.HTTP
+	}
+	if u == nil {
+		return nil, nil
+	}
+
+	// Add the credentials if the proxy url doesn't have its own.
+	if u.User == nil && p.Username != "" {
+		c := *u
+		c.User = url.UserPassword(p.Username, p.Password)
+		u = &c
+	}
+	return u, nil
+}
+
+// bypass determines if the given url matches the no-proxy list.
+func (p *ProxyConfig) bypass(u *url.URL) bool {
+	host := strings.ToLower(u.Hostname())
+	hostport := strings.ToLower(u.Host)
+	ip := net.ParseIP(host)
+
+	for _, np := range p.NoProxy {
+		switch {
+		case np == "*":
+			return true
+		case np == host || np == hostport:
+			return true
+		case strings.HasPrefix(np, "."):
+			if strings.HasSuffix(host, np) {
+				return true
+			}
+		case strings.HasSuffix(host, "."+np):
+			return true
+		}
+
+		if _, cidr, err := net.ParseCIDR(np); err == nil && ip != nil && cidr.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -6,7 +6,15 @@ import (
 	"strings"
 )
 
+// The types of requests that can be made.
+const (
+	RequestTypeHTTP      = "http"
+	RequestTypeWebSocket = "websocket"
+	RequestTypeGRPC      = "grpc"
+)
+
 type Request struct {
+	Type           string            `yaml:"type,omitempty"`
 	Description    string            `yaml:"description,omitempty"`
 	URL            string            `yaml:"url"`
 	Method         string            `yaml:"method"`
@@ -14,6 +22,28 @@ type Request struct {
 	Authentication map[string]string `yaml:"authentication"`
 	Query          map[string]string `yaml:"query"`
 	Body           Body              `yaml:"body,omitempty"`
+	Protocol       string            `yaml:"protocol,omitempty"`
+	AcceptEncoding string            `yaml:"accept-encoding,omitempty"`
+	WebSocket      []WebSocketStep   `yaml:"websocket,omitempty"`
+	GRPC           GRPCOptions       `yaml:"grpc,omitempty"`
+	Snapshot       SnapshotOptions   `yaml:"snapshot,omitempty"`
+	TLS            TLSOptions        `yaml:"tls,omitempty"`
+	Schema         string            `yaml:"schema,omitempty"`
+
+	// dir is the folder of the file the request is in. The schema is
+	// relative to it.
+	dir string
+}
+
+// TLSOptions configure TLS for a single request. Cert and Key are PEM
+// files of a client certificate (the key can be in the cert file) and
+// CA is a PEM file of the authorities to trust instead of the system
+// ones.
+type TLSOptions struct {
+	Insecure bool   `yaml:"insecure,omitempty"`
+	Cert     string `yaml:"cert,omitempty"`
+	Key      string `yaml:"key,omitempty"`
+	CA       string `yaml:"ca,omitempty"`
 }
 
 type Body struct {
@@ -24,6 +54,8 @@ type Body struct {
 func (r *Request) Interpolate(vars map[string]string) {
 	r.URL = interpolate(r.URL, vars)
 	r.Method = interpolate(r.Method, vars)
+	r.Protocol = interpolate(r.Protocol, vars)
+	r.AcceptEncoding = interpolate(r.AcceptEncoding, vars)
 	r.Body.Type = interpolate(r.Body.Type, vars)
 	r.Body.Value = interpolate(r.Body.Value, vars)
 
@@ -38,6 +70,20 @@ func (r *Request) Interpolate(vars map[string]string) {
 	for k, v := range r.Query {
 		r.Query[k] = interpolate(v, vars)
 	}
+
+	r.TLS.Cert = interpolate(r.TLS.Cert, vars)
+	r.TLS.Key = interpolate(r.TLS.Key, vars)
+	r.TLS.CA = interpolate(r.TLS.CA, vars)
+	r.Schema = interpolate(r.Schema, vars)
+
+	r.GRPC.Service = interpolate(r.GRPC.Service, vars)
+	r.GRPC.Method = interpolate(r.GRPC.Method, vars)
+	r.GRPC.Protoset = interpolate(r.GRPC.Protoset, vars)
+
+	for x := range r.WebSocket {
+		r.WebSocket[x].Send = interpolate(r.WebSocket[x].Send, vars)
+		r.WebSocket[x].Expect = interpolate(r.WebSocket[x].Expect, vars)
+	}
 }
 
 var re = regexp.MustCompile(`\{\{[^\}]*\}\}`)
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// NewResolveMap creates the host to address mapping used when
+// dialing. Like curl's --resolve, this lets you send requests to a
+// different address while keeping the original Host header and SNI.
+// Mappings come from the resolve map of the environment:
+//
+//	resolve:
+//	  example.com:443: 10.0.0.5
+//	  api.example.com: 10.0.0.6:8443
+//
+// and the resolve preference, which is a comma-separated list of
+// curl-style host:port:address entries. Environment mappings take
+// precedence.
+func NewResolveMap(vars map[string]string, prefs map[string]string) (map[string]string, error) {
+	resolve := map[string]string{}
+
+	for _, entry := range strings.Split(prefs["resolve"], ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, ":", 3)
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("invalid resolve preference '%v' (expected host:port:address)", entry)
+		}
+		resolve[strings.ToLower(net.JoinHostPort(parts[0], parts[1]))] = strings.Trim(parts[2], "[]")
+	}
+
+	// The flattened key is everything after the prefix since host
+	// names contain dots.
+	const prefix = "environment.resolve."
+	for k, v := range vars {
+		if strings.HasPrefix(k, prefix) {
+			resolve[strings.ToLower(strings.TrimPrefix(k, prefix))] = v
+		}
+	}
+
+	return resolve, nil
+}
+
+// resolveAddr returns the address to dial for the given host:port
+// address. Exact host:port matches win over host only matches. If
+// the mapped address has no port, the original port is kept.
+func resolveAddr(resolve map[string]string, addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+
+	to, ok := resolve[strings.ToLower(addr)]
+	if !ok {
+		if to, ok = resolve[strings.ToLower(host)]; !ok {
+			return addr
+		}
+	}
+
+	if _, _, err := net.SplitHostPort(to); err == nil {
+		return to
+	}
+	return net.JoinHostPort(strings.Trim(to, "[]"), port)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNewResolveMap(t *testing.T) {
+	tests := []struct {
+		name  string
+		vars  map[string]string
+		prefs map[string]string
+		want  map[string]string
+		err   string
+	}{
+		{"empty", nil, nil, map[string]string{}, ""},
+		{"preference", nil, map[string]string{"resolve": "example.com:443:10.0.0.5, API.example.com:80:[::1],"}, map[string]string{
+			"example.com:443":    "10.0.0.5",
+			"api.example.com:80": "::1",
+		}, ""},
+		{"environment", map[string]string{
+			"environment.resolve.Example.com:443": "10.0.0.5",
+			"environment.resolve.api.example.com": "10.0.0.6:8443",
+			"environment.other":                   "x",
+		}, nil, map[string]string{
+			"example.com:443": "10.0.0.5",
+			"api.example.com": "10.0.0.6:8443",
+		}, ""},
+		{"environment wins", map[string]string{"environment.resolve.example.com:443": "10.0.0.6"},
+			map[string]string{"resolve": "example.com:443:10.0.0.5"}, map[string]string{"example.com:443": "10.0.0.6"}, ""},
+		{"invalid", nil, map[string]string{"resolve": "example.com:10.0.0.5"}, nil, "invalid resolve preference 'example.com:10.0.0.5'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewResolveMap(tt.vars, tt.prefs)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got error %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveAddr(t *testing.T) {
+	resolve := map[string]string{
+		"example.com:443":   "10.0.0.5",
+		"example.com":       "10.0.0.6",
+		"api.example.com":   "10.0.0.7:8443",
+		"v6.example.com":    "[::1]",
+		"v6.example.com:80": "[::2]:8080",
+	}
+	tests := []struct {
+		addr string
+		want string
+	}{
+		{"example.com:443", "10.0.0.5:443"},
+		{"EXAMPLE.com:443", "10.0.0.5:443"},
+		{"example.com:80", "10.0.0.6:80"},
+		{"api.example.com:443", "10.0.0.7:8443"},
+		{"v6.example.com:443", "[::1]:443"},
+		{"v6.example.com:80", "[::2]:8080"},
+		{"other.example.com:443", "other.example.com:443"},
+		{"example.com", "example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			if got := resolveAddr(resolve, tt.addr); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -12,34 +12,93 @@ type Response struct {
 	Status     string            `yaml:"status"`
 	StatusCode int               `yaml:"status-code"`
 	Duration   time.Duration     `yaml:"duration"`
+	Timings    Timings           `yaml:"timings"`
+	Protocol   string            `yaml:"protocol,omitempty"`
+	ALPN       string            `yaml:"alpn,omitempty"`
 	Cookies    map[string]string `yaml:"cookies"`
 	Headers    map[string]string `yaml:"headers"`
 	Body       string            `yaml:"body"`
+
+	// RepeatedHeaders are the values of the headers that were sent
+	// more than once (e.g. Set-Cookie). Headers has them joined.
+	RepeatedHeaders map[string][]string `yaml:"repeated-headers,omitempty"`
+
+	// Size is the size of the decoded body. If the body had a
+	// content encoding, its size on the wire is CompressedSize.
+	Size           int64  `yaml:"size"`
+	Encoding       string `yaml:"encoding,omitempty"`
+	CompressedSize int64  `yaml:"compressed-size,omitempty"`
+
+	// Events are the server-sent events of a streamed response.
+	Events []Event `yaml:"events,omitempty"`
+
+	// Messages is the transcript of a websocket request.
+	Messages []WebSocketMessage `yaml:"messages,omitempty"`
+
+	// display is the body as it was shown to the user.
+	display string
+}
+
+// setHeader saves the values of a header.
+func (r *Response) setHeader(k string, v []string) {
+	r.Headers[k] = fmt.Sprintf("%s", v)
+	if len(v) > 1 {
+		if r.RepeatedHeaders == nil {
+			r.RepeatedHeaders = map[string][]string{}
+		}
+		r.RepeatedHeaders[k] = v
+	}
+}
+
+// header is the values of a saved header.
+func (r *Response) header(k string) []string {
+	if v, ok := r.RepeatedHeaders[k]; ok {
+		return v
+	}
+	return []string{headerValue(r.Headers[k])}
 }
 
 // Flatten the JSON of the body to the given map where
-// hierarchy uses dot-notation instead of nested maps.
+// hierarchy uses dot-notation instead of nested maps. Array
+// elements use their index (e.g. responses.name.items.0.id).
 func (r *Response) Flatten(m map[string]string, name string) error {
-	e := map[string]interface{}{}
+	return r.FlattenPrefix(m, "responses."+name)
+}
+
+// FlattenPrefix flattens the JSON of the body with keys starting with
+// the given prefix.
+func (r *Response) FlattenPrefix(m map[string]string, prefix string) error {
+	var e interface{}
 	err := json.Unmarshal([]byte(r.Body), &e)
 	if err != nil {
 		return err
 	}
 
-	flattenHelperJSON(e, m, "responses."+name)
+	flattenHelperJSON(e, m, prefix)
 	return nil
 }
 
-func flattenHelperJSON(e map[string]interface{}, result map[string]string, prefix string) {
-	for key, v := range e {
-		if v == nil {
-			v = ""
+func flattenHelperJSON(e interface{}, result map[string]string, prefix string) {
+	switch e := e.(type) {
+	case map[string]interface{}:
+		for key, v := range e {
+			flattenValueJSON(v, result, prefix+"."+key)
 		}
-		t := reflect.TypeOf(v).Kind()
-		if t == reflect.Int || t == reflect.Float32 || t == reflect.Float64 || t == reflect.String || t == reflect.Bool {
-			result[prefix+"."+key] = fmt.Sprintf("%v", v)
-		} else if s, ok := v.(map[string]interface{}); ok {
-			flattenHelperJSON(s, result, prefix+"."+key)
+	case []interface{}:
+		for x, v := range e {
+			flattenValueJSON(v, result, fmt.Sprintf("%v.%v", prefix, x))
 		}
 	}
 }
+
+func flattenValueJSON(v interface{}, result map[string]string, key string) {
+	if v == nil {
+		v = ""
+	}
+	t := reflect.TypeOf(v).Kind()
+	if t == reflect.Int || t == reflect.Float32 || t == reflect.Float64 || t == reflect.String || t == reflect.Bool {
+		result[key] = fmt.Sprintf("%v", v)
+	} else {
+		flattenHelperJSON(v, result, key)
+	}
+}
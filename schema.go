@@ -0,0 +1,689 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/mail"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gookit/color"
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// SchemaError is a value that doesn't match its schema.
+type SchemaError struct {
+	// Path is where the value is (e.g. $.items[2].id).
+	Path    string
+	Message string
+}
+
+func (e SchemaError) String() string {
+	return e.Path + ": " + e.Message
+}
+
+// schemaValidator validates values decoded by decodeJSON against JSON
+// Schemas (draft-07 and 2020-12) and OpenAPI schemas. References are
+// resolved in the root document.
+type schemaValidator struct {
+	root interface{}
+
+	// nullable is supported for OpenAPI 3.0, which doesn't have null
+	// types.
+	nullable bool
+
+	// anchors are the schemas with an $id or $anchor.
+	anchors map[string]interface{}
+}
+
+func newSchemaValidator(root interface{}, nullable bool) *schemaValidator {
+	s := &schemaValidator{root: root, nullable: nullable, anchors: map[string]interface{}{}}
+	s.findAnchors(root)
+	return s
+}
+
+func (s *schemaValidator) findAnchors(v interface{}) {
+	switch t := v.(type) {
+	case *jsonObject:
+		for _, k := range []string{"$id", "$anchor"} {
+			if id, ok := t.values[k].(string); ok && id != "" {
+				s.anchors[id] = t
+				if k == "$anchor" {
+					s.anchors["#"+id] = t
+				}
+			}
+		}
+		for _, k := range t.keys {
+			s.findAnchors(t.values[k])
+		}
+	case []interface{}:
+		for _, i := range t {
+			s.findAnchors(i)
+		}
+	}
+}
+
+// Validate checks the value against the schema.
+func (s *schemaValidator) Validate(schema, v interface{}) []SchemaError {
+	errs := []SchemaError{}
+	s.validate(schema, v, "$", 0, &errs)
+	return errs
+}
+
+func (s *schemaValidator) resolve(ref string) (interface{}, error) {
+	if v, ok := s.anchors[ref]; ok {
+		return v, nil
+	}
+	i := strings.Index(ref, "#")
+	if i < 0 {
+		return nil, fmt.Errorf("reference %v not found", ref)
+	}
+	base, pointer := ref[:i], ref[i+1:]
+	v := s.root
+	if base != "" {
+		if a, ok := s.anchors[base]; ok {
+			v = a
+		} else {
+			return nil, fmt.Errorf("external reference %v isn't supported", ref)
+		}
+	}
+	if pointer == "" {
+		return v, nil
+	}
+	for _, part := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		part, _ = url.PathUnescape(part)
+		part = strings.ReplaceAll(strings.ReplaceAll(part, "~1", "/"), "~0", "~")
+		switch t := v.(type) {
+		case *jsonObject:
+			next, ok := t.Get(part)
+			if !ok {
+				return nil, fmt.Errorf("reference %v not found", ref)
+			}
+			v = next
+		case []interface{}:
+			var x int
+			if _, err := fmt.Sscanf(part, "%d", &x); err != nil || x < 0 || x >= len(t) {
+				return nil, fmt.Errorf("reference %v not found", ref)
+			}
+			v = t[x]
+		default:
+			return nil, fmt.Errorf("reference %v not found", ref)
+		}
+	}
+	return v, nil
+}
+
+// unsupportedSchemaKeywords are the keywords the validator doesn't
+// implement. Schemas using them are reported instead of passing.
+var unsupportedSchemaKeywords = []string{
+	"dependencies", "dependentSchemas", "unevaluatedProperties",
+	"unevaluatedItems", "$dynamicRef", "$recursiveRef",
+}
+
+// matches determines if the value is valid without adding errors. It's
+// used by the keywords where failing a schema isn't an error.
+func (s *schemaValidator) matches(schema, v interface{}, refs int) bool {
+	errs := []SchemaError{}
+	s.validate(schema, v, "$", refs, &errs)
+	return len(errs) == 0
+}
+
+// validate adds the ways v doesn't match the schema to errs. refs is
+// the number of references followed without getting to a part of the
+// value, which stops schemas that refer to themselves forever. The
+// items and properties of the value start again at zero.
+func (s *schemaValidator) validate(schema, v interface{}, path string, refs int, errs *[]SchemaError) {
+	fail := func(format string, a ...interface{}) {
+		*errs = append(*errs, SchemaError{Path: path, Message: fmt.Sprintf(format, a...)})
+	}
+	if refs > 64 {
+		fail("too many nested references (recursive reference?)")
+		return
+	}
+
+	// Boolean schemas allow everything or nothing.
+	switch t := schema.(type) {
+	case bool:
+		if !t {
+			fail("no value is allowed")
+		}
+		return
+	case *jsonObject:
+	default:
+		return
+	}
+	sch := schema.(*jsonObject)
+	get := func(k string) (interface{}, bool) { return sch.Get(k) }
+
+	// Keywords that aren't implemented would pass everything.
+	for _, k := range unsupportedSchemaKeywords {
+		if _, ok := get(k); ok {
+			fail("the %v keyword isn't supported", k)
+		}
+	}
+
+	if ref, ok := get("$ref"); ok {
+		if r, ok := ref.(string); ok {
+			target, err := s.resolve(r)
+			if err != nil {
+				fail("%v", err)
+				return
+			}
+			s.validate(target, v, path, refs+1, errs)
+		}
+	}
+
+	if v == nil && s.nullable {
+		if n, _ := get("nullable"); n == true {
+			return
+		}
+	}
+
+	if t, ok := get("type"); ok {
+		types := []string{}
+		switch tt := t.(type) {
+		case string:
+			types = append(types, tt)
+		case []interface{}:
+			for _, i := range tt {
+				if name, ok := i.(string); ok {
+					types = append(types, name)
+				}
+			}
+		}
+		matched := false
+		for _, t := range types {
+			if schemaType(v, t) {
+				matched = true
+			}
+		}
+		if !matched {
+			fail("expected %v but got %v", strings.Join(types, " or "), jsonType(v))
+			return
+		}
+	}
+
+	if e, ok := get("enum"); ok {
+		if options, ok := e.([]interface{}); ok {
+			found := false
+			for _, o := range options {
+				if jsonEqual(o, v) {
+					found = true
+				}
+			}
+			if !found {
+				fail("%v isn't one of %v", jsonString(v), jsonString(options))
+			}
+		}
+	}
+	if c, ok := get("const"); ok && !jsonEqual(c, v) {
+		fail("expected %v but got %v", jsonString(c), jsonString(v))
+	}
+
+	switch t := v.(type) {
+	case *jsonObject:
+		s.validateObject(sch, t, path, errs)
+	case []interface{}:
+		s.validateArray(sch, t, path, errs)
+	case string:
+		s.validateString(sch, t, fail)
+	case json.Number:
+		validateNumber(sch, t, fail)
+	}
+
+	// Combinations.
+	if all, ok := get("allOf"); ok {
+		for _, a := range schemaList(all) {
+			s.validate(a, v, path, refs, errs)
+		}
+	}
+	if anyOf, ok := get("anyOf"); ok {
+		matched := false
+		for _, a := range schemaList(anyOf) {
+			if s.matches(a, v, refs) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			fail("doesn't match any of the anyOf schemas")
+		}
+	}
+	if oneOf, ok := get("oneOf"); ok {
+		matched := 0
+		for _, a := range schemaList(oneOf) {
+			if s.matches(a, v, refs) {
+				matched++
+			}
+		}
+		if matched != 1 {
+			fail("matches %v of the oneOf schemas instead of one", matched)
+		}
+	}
+	if not, ok := get("not"); ok && s.matches(not, v, refs) {
+		fail("matches the schema it must not match")
+	}
+	if cond, ok := get("if"); ok {
+		if s.matches(cond, v, refs) {
+			if then, ok := get("then"); ok {
+				s.validate(then, v, path, refs, errs)
+			}
+		} else if els, ok := get("else"); ok {
+			s.validate(els, v, path, refs, errs)
+		}
+	}
+}
+
+func (s *schemaValidator) validateObject(sch *jsonObject, o *jsonObject, path string, errs *[]SchemaError) {
+	fail := func(format string, a ...interface{}) {
+		*errs = append(*errs, SchemaError{Path: path, Message: fmt.Sprintf(format, a...)})
+	}
+
+	if req, ok := sch.Get("required"); ok {
+		for _, r := range schemaList(req) {
+			if k, ok := r.(string); ok {
+				if _, ok := o.Get(k); !ok {
+					fail("missing required property '%v'", k)
+				}
+			}
+		}
+	}
+	if dr, ok := sch.Get("dependentRequired"); ok {
+		if deps, ok := dr.(*jsonObject); ok {
+			for _, k := range deps.keys {
+				if _, ok := o.Get(k); !ok {
+					continue
+				}
+				for _, r := range schemaList(deps.values[k]) {
+					if rk, ok := r.(string); ok {
+						if _, ok := o.Get(rk); !ok {
+							fail("'%v' requires property '%v'", k, rk)
+						}
+					}
+				}
+			}
+		}
+	}
+	if n, ok := schemaInt(sch, "minProperties"); ok && len(o.keys) < n {
+		fail("has %v properties but needs at least %v", len(o.keys), n)
+	}
+	if n, ok := schemaInt(sch, "maxProperties"); ok && len(o.keys) > n {
+		fail("has %v properties but can have at most %v", len(o.keys), n)
+	}
+
+	props, _ := sch.Get("properties")
+	properties, _ := props.(*jsonObject)
+	pp, _ := sch.Get("patternProperties")
+	patterns, _ := pp.(*jsonObject)
+	additional, hasAdditional := sch.Get("additionalProperties")
+	names, hasNames := sch.Get("propertyNames")
+
+	for _, k := range o.keys {
+		p := path + pathKey(k)
+		v := o.values[k]
+		if hasNames {
+			nameErrs := []SchemaError{}
+			s.validate(names, k, p, 0, &nameErrs)
+			for _, e := range nameErrs {
+				fail("property name '%v': %v", k, e.Message)
+			}
+		}
+
+		matched := false
+		if properties != nil {
+			if ps, ok := properties.Get(k); ok {
+				matched = true
+				s.validate(ps, v, p, 0, errs)
+			}
+		}
+		if patterns != nil {
+			for _, pattern := range patterns.keys {
+				re, err := regexp.Compile(pattern)
+				if err != nil || !re.MatchString(k) {
+					continue
+				}
+				matched = true
+				s.validate(patterns.values[pattern], v, p, 0, errs)
+			}
+		}
+		if !matched && hasAdditional {
+			if additional == false {
+				fail("property '%v' isn't allowed", k)
+			} else {
+				s.validate(additional, v, p, 0, errs)
+			}
+		}
+	}
+}
+
+func (s *schemaValidator) validateArray(sch *jsonObject, a []interface{}, path string, errs *[]SchemaError) {
+	fail := func(format string, args ...interface{}) {
+		*errs = append(*errs, SchemaError{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+	index := func(x int) string { return fmt.Sprintf("%v[%v]", path, x) }
+
+	if n, ok := schemaInt(sch, "minItems"); ok && len(a) < n {
+		fail("has %v items but needs at least %v", len(a), n)
+	}
+	if n, ok := schemaInt(sch, "maxItems"); ok && len(a) > n {
+		fail("has %v items but can have at most %v", len(a), n)
+	}
+	if u, _ := sch.Get("uniqueItems"); u == true {
+		for x := range a {
+			for y := x + 1; y < len(a); y++ {
+				if jsonEqual(a[x], a[y]) {
+					fail("items %v and %v are the same", x, y)
+				}
+			}
+		}
+	}
+
+	// Tuples are prefixItems in 2020-12 and a list of items before.
+	prefix := 0
+	if pi, ok := sch.Get("prefixItems"); ok {
+		for x, ps := range schemaList(pi) {
+			if x < len(a) {
+				s.validate(ps, a[x], index(x), 0, errs)
+			}
+			prefix++
+		}
+	}
+	rest, hasRest := sch.Get("items")
+	if tuple, ok := rest.([]interface{}); ok {
+		for x, ps := range tuple {
+			if x < len(a) {
+				s.validate(ps, a[x], index(x), 0, errs)
+			}
+		}
+		prefix = len(tuple)
+		rest, hasRest = sch.Get("additionalItems")
+	}
+	if hasRest {
+		for x := prefix; x < len(a); x++ {
+			if rest == false {
+				fail("can have at most %v items", prefix)
+				break
+			}
+			s.validate(rest, a[x], index(x), 0, errs)
+		}
+	}
+
+	if c, ok := sch.Get("contains"); ok {
+		count := 0
+		for _, i := range a {
+			if s.matches(c, i, 0) {
+				count++
+			}
+		}
+		min, ok := schemaInt(sch, "minContains")
+		if !ok {
+			min = 1
+		}
+		if count < min {
+			fail("contains %v matching items but needs at least %v", count, min)
+		}
+		if max, ok := schemaInt(sch, "maxContains"); ok && count > max {
+			fail("contains %v matching items but can have at most %v", count, max)
+		}
+	}
+}
+
+func (s *schemaValidator) validateString(sch *jsonObject, v string, fail func(string, ...interface{})) {
+	length := utf8.RuneCountInString(v)
+	if n, ok := schemaInt(sch, "minLength"); ok && length < n {
+		fail("is %v characters but needs at least %v", length, n)
+	}
+	if n, ok := schemaInt(sch, "maxLength"); ok && length > n {
+		fail("is %v characters but can have at most %v", length, n)
+	}
+	if p, ok := sch.Get("pattern"); ok {
+		if pattern, ok := p.(string); ok {
+			if re, err := regexp.Compile(pattern); err == nil && !re.MatchString(v) {
+				fail("%v doesn't match the pattern %v", quoteJSON(v), pattern)
+			}
+		}
+	}
+	if f, ok := sch.Get("format"); ok {
+		if format, ok := f.(string); ok && !validFormat(format, v) {
+			fail("%v isn't a valid %v", quoteJSON(v), format)
+		}
+	}
+}
+
+func validateNumber(sch *jsonObject, n json.Number, fail func(string, ...interface{})) {
+	v, ok := new(big.Rat).SetString(string(n))
+	if !ok {
+		return
+	}
+	limit := func(k string) (*big.Rat, bool) {
+		l, ok := sch.Get(k)
+		if !ok {
+			return nil, false
+		}
+		ln, ok := l.(json.Number)
+		if !ok {
+			return nil, false
+		}
+		return new(big.Rat).SetString(string(ln))
+	}
+
+	// OpenAPI 3.0 and draft-04 use booleans to make the limits
+	// exclusive.
+	exMin, _ := sch.Get("exclusiveMinimum")
+	exMax, _ := sch.Get("exclusiveMaximum")
+	if min, ok := limit("minimum"); ok {
+		if exMin == true && v.Cmp(min) <= 0 {
+			fail("%v must be greater than %v", n, min.RatString())
+		} else if v.Cmp(min) < 0 {
+			fail("%v is less than the minimum %v", n, min.RatString())
+		}
+	}
+	if max, ok := limit("maximum"); ok {
+		if exMax == true && v.Cmp(max) >= 0 {
+			fail("%v must be less than %v", n, max.RatString())
+		} else if v.Cmp(max) > 0 {
+			fail("%v is greater than the maximum %v", n, max.RatString())
+		}
+	}
+	if min, ok := limit("exclusiveMinimum"); ok && v.Cmp(min) <= 0 {
+		fail("%v must be greater than %v", n, min.RatString())
+	}
+	if max, ok := limit("exclusiveMaximum"); ok && v.Cmp(max) >= 0 {
+		fail("%v must be less than %v", n, max.RatString())
+	}
+	if m, ok := limit("multipleOf"); ok && m.Sign() != 0 {
+		if !new(big.Rat).Quo(v, m).IsInt() {
+			fail("%v isn't a multiple of %v", n, m.RatString())
+		}
+	}
+}
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// validFormat checks the common formats. Unknown formats are allowed.
+func validFormat(format, v string) bool {
+	switch format {
+	case "date-time":
+		_, err := time.Parse(time.RFC3339Nano, v)
+		return err == nil
+	case "date":
+		_, err := time.Parse("2006-01-02", v)
+		return err == nil
+	case "time":
+		_, err := time.Parse("15:04:05Z07:00", v)
+		if err != nil {
+			_, err = time.Parse("15:04:05.999999999Z07:00", v)
+		}
+		return err == nil
+	case "email":
+		a, err := mail.ParseAddress(v)
+		return err == nil && a.Address == v
+	case "uuid":
+		return uuidPattern.MatchString(v)
+	case "ipv4":
+		ip := net.ParseIP(v)
+		return ip != nil && ip.To4() != nil && strings.Contains(v, ".")
+	case "ipv6":
+		ip := net.ParseIP(v)
+		return ip != nil && strings.Contains(v, ":")
+	case "uri", "url":
+		u, err := url.Parse(v)
+		return err == nil && u.Scheme != ""
+	case "regex":
+		_, err := regexp.Compile(v)
+		return err == nil
+	}
+	return true
+}
+
+// schemaType determines if the value is of the JSON Schema type.
+func schemaType(v interface{}, t string) bool {
+	switch t {
+	case "integer":
+		n, ok := v.(json.Number)
+		if !ok {
+			return false
+		}
+		r, ok := new(big.Rat).SetString(string(n))
+		return ok && r.IsInt()
+	case "number":
+		_, ok := v.(json.Number)
+		return ok
+	}
+	return jsonType(v) == t
+}
+
+// jsonType is the JSON Schema type of a decoded value.
+func jsonType(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		return "number"
+	case []interface{}:
+		return "array"
+	case *jsonObject:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// jsonEqual compares decoded values. The order of keys and how
+// numbers are written don't matter.
+func jsonEqual(a, b interface{}) bool {
+	switch at := a.(type) {
+	case *jsonObject:
+		bt, ok := b.(*jsonObject)
+		if !ok || len(at.keys) != len(bt.keys) {
+			return false
+		}
+		for _, k := range at.keys {
+			bv, ok := bt.Get(k)
+			if !ok || !jsonEqual(at.values[k], bv) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		bt, ok := b.([]interface{})
+		if !ok || len(at) != len(bt) {
+			return false
+		}
+		for x := range at {
+			if !jsonEqual(at[x], bt[x]) {
+				return false
+			}
+		}
+		return true
+	case json.Number:
+		bt, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		ar, aok := new(big.Rat).SetString(string(at))
+		br, bok := new(big.Rat).SetString(string(bt))
+		return aok && bok && ar.Cmp(br) == 0
+	}
+	return a == b
+}
+
+func schemaList(v interface{}) []interface{} {
+	l, _ := v.([]interface{})
+	return l
+}
+
+func schemaInt(sch *jsonObject, k string) (int, bool) {
+	v, ok := sch.Get(k)
+	if !ok {
+		return 0, false
+	}
+	n, ok := v.(json.Number)
+	if !ok {
+		return 0, false
+	}
+	i, err := n.Int64()
+	return int(i), err == nil
+}
+
+// loadSchema reads a JSON Schema (JSON or YAML).
+func loadSchema(path string) (interface{}, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	root := &yaml.Node{}
+	if err := yaml.Unmarshal(buf, root); err != nil {
+		return nil, fmt.Errorf("parsing %v: %v", path, err)
+	}
+	return nodeValue(root), nil
+}
+
+// checkSchema validates the response body against the JSON Schema of
+// the request and prints what doesn't match. Relative paths are
+// relative to the file the request is in.
+func checkSchema(c *cli.Context, r Request, resp *Response) ([]string, error) {
+	path := r.Schema
+	if !filepath.IsAbs(path) {
+		dir := r.dir
+		if dir == "" {
+			dir = configDir(c)
+		}
+		path = filepath.Join(dir, path)
+	}
+	schema, err := loadSchema(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var errs []SchemaError
+	body, err := decodeJSON([]byte(resp.Body))
+	if err != nil {
+		errs = []SchemaError{{Path: "$", Message: fmt.Sprintf("body isn't valid JSON: %v", err)}}
+	} else {
+		errs = newSchemaValidator(schema, false).Validate(schema, body)
+	}
+	if len(errs) == 0 {
+		color.Green.Printf("schema: %v ok\n", path)
+		return nil, nil
+	}
+
+	color.Red.Printf("schema: %v\n", path)
+	violations := []string{}
+	for _, e := range errs {
+		color.Red.Printf("  <%v>\n", e)
+		violations = append(violations, "schema: "+e.String())
+	}
+	return violations, nil
+}
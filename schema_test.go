@@ -0,0 +1,75 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSchemaValidator(t *testing.T) {
+	// list is a linked list deeper than the reference limit.
+	list := strings.Repeat(`{"next": `, 100) + "null" + strings.Repeat("}", 100)
+
+	tests := []struct {
+		name     string
+		schema   string
+		value    string
+		nullable bool
+		want     []string
+	}{
+		{"type", `{"type": "string"}`, `1`, false, []string{"$: expected string but got number"}},
+		{"type list", `{"type": ["string", "null"]}`, `null`, false, nil},
+		{"nullable", `{"type": "string", "nullable": true}`, `null`, true, nil},
+		{"nullable draft", `{"type": "string", "nullable": true}`, `null`, false, []string{"$: expected string but got null"}},
+		{"required", `{"required": ["id"]}`, `{}`, false, []string{"$: missing required property 'id'"}},
+		{
+			"nested path",
+			`{"properties": {"items": {"items": {"properties": {"id": {"type": "integer"}}}}}}`,
+			`{"items": [{"id": 1}, {"id": "2"}]}`, false,
+			[]string{"$.items[1].id: expected integer but got string"},
+		},
+		{"enum", `{"enum": ["a", "b"]}`, `"c"`, false, []string{`$: "c" isn't one of ["a","b"]`}},
+		{"additional", `{"properties": {"a": {}}, "additionalProperties": false}`, `{"a": 1, "b": 2}`, false, []string{"$: property 'b' isn't allowed"}},
+		{"anyOf", `{"anyOf": [{"type": "string"}, {"type": "integer"}]}`, `1`, false, nil},
+		{"anyOf none", `{"anyOf": [{"type": "string"}, {"type": "boolean"}]}`, `1`, false, []string{"$: doesn't match any of the anyOf schemas"}},
+		{"oneOf two", `{"oneOf": [{"type": "integer"}, {"minimum": 0}]}`, `1`, false, []string{"$: matches 2 of the oneOf schemas instead of one"}},
+		{"not", `{"not": {"type": "null"}}`, `null`, false, []string{"$: matches the schema it must not match"}},
+		{"if then", `{"if": {"type": "string"}, "then": {"minLength": 2}, "else": {"minimum": 5}}`, `"a"`, false, []string{"$: is 1 characters but needs at least 2"}},
+		{"if else", `{"if": {"type": "string"}, "then": {"minLength": 2}, "else": {"minimum": 5}}`, `6`, false, nil},
+		{"propertyNames", `{"propertyNames": {"pattern": "^[a-z]+$"}}`, `{"ok": 1, "Bad": 2}`, false, []string{`$: property name 'Bad': "Bad" doesn't match the pattern ^[a-z]+$`}},
+		{"contains", `{"contains": {"type": "string"}, "minContains": 2}`, `["a", 1]`, false, []string{"$: contains 1 matching items but needs at least 2"}},
+		{
+			"recursive list",
+			`{"$defs": {"node": {"type": "object", "properties": {"next": {"anyOf": [{"type": "null"}, {"$ref": "#/$defs/node"}]}}}}, "$ref": "#/$defs/node"}`,
+			list, false, nil,
+		},
+		{
+			"recursive list invalid",
+			`{"$defs": {"node": {"type": "object", "properties": {"next": {"anyOf": [{"type": "null"}, {"$ref": "#/$defs/node"}]}}}}, "$ref": "#/$defs/node"}`,
+			`{"next": {"next": 1}}`, false,
+			[]string{"$.next: doesn't match any of the anyOf schemas"},
+		},
+		{"self reference", `{"anyOf": [{"$ref": "#"}]}`, `1`, false, []string{"$: doesn't match any of the anyOf schemas"}},
+		{"unsupported", `{"properties": {"a": {"unevaluatedProperties": false}}}`, `{"a": {"b": 1}}`, false, []string{"$.a: the unevaluatedProperties keyword isn't supported"}},
+		{"unsupported property name", `{"properties": {"dependencies": {"type": "array"}}}`, `{"dependencies": []}`, false, nil},
+		{"ref loop", `{"$defs": {"a": {"$ref": "#/$defs/b"}, "b": {"$ref": "#/$defs/a"}}, "$ref": "#/$defs/a"}`, `1`, false, []string{"$: too many nested references (recursive reference?)"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema, err := decodeJSON([]byte(tt.schema))
+			if err != nil {
+				t.Fatal(err)
+			}
+			value, err := decodeJSON([]byte(tt.value))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := []string{}
+			for _, e := range newSchemaValidator(schema, tt.nullable).Validate(schema, value) {
+				got = append(got, e.String())
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gookit/color"
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// SnapshotDir is the folder in the config folder where the approved
+// snapshots of responses are kept.
+const SnapshotDir = "snapshots"
+
+// DefaultSnapshotIgnoreHeaders are the headers that change on every
+// request (or whenever an ignored part of the body does).
+var DefaultSnapshotIgnoreHeaders = []string{"Date", "Content-Length"}
+
+// SnapshotOptions are the parts of a response that change between
+// runs and shouldn't be compared to the snapshot. Ignore is a list of
+// JSON paths in the body (e.g. $.updatedAt or $.items[*].id).
+type SnapshotOptions struct {
+	Ignore        []string `yaml:"ignore,omitempty"`
+	IgnoreHeaders []string `yaml:"ignore-headers,omitempty"`
+}
+
+// Snapshot is the approved response of a request. It has the parts
+// of a Response that should be the same on every run.
+type Snapshot struct {
+	Status     string            `yaml:"status"`
+	StatusCode int               `yaml:"status-code"`
+	Headers    map[string]string `yaml:"headers"`
+	Body       string            `yaml:"body"`
+}
+
+// newSnapshot creates the snapshot of a response.
+func newSnapshot(r *Response, opts SnapshotOptions) (Snapshot, error) {
+	s := Snapshot{
+		Status:     r.Status,
+		StatusCode: r.StatusCode,
+		Headers:    r.Headers,
+		Body:       r.Body,
+	}
+	return s.normalize(opts)
+}
+
+// normalize removes the ignored parts of the snapshot. JSON bodies
+// are pretty printed so they are easy to read and review.
+func (s Snapshot) normalize(opts SnapshotOptions) (Snapshot, error) {
+	ignore := map[string]bool{}
+	for _, h := range append(DefaultSnapshotIgnoreHeaders, opts.IgnoreHeaders...) {
+		ignore[strings.ToLower(h)] = true
+	}
+	headers := map[string]string{}
+	for k, v := range s.Headers {
+		if !ignore[strings.ToLower(k)] {
+			headers[k] = v
+		}
+	}
+	s.Headers = headers
+
+	v, err := decodeJSON([]byte(s.Body))
+	if err != nil {
+		if len(opts.Ignore) > 0 {
+			return s, fmt.Errorf("ignoring body paths: %v", err)
+		}
+		return s, nil
+	}
+	for _, i := range opts.Ignore {
+		p, err := ParseJSONPath(i)
+		if err != nil {
+			return s, fmt.Errorf("parsing ignore '%v': %v", i, err)
+		}
+		v = p.Delete(v)
+	}
+	s.Body = string(encodeJSON(v, "  ", PlainPalette))
+	return s, nil
+}
+
+// snapshotPath is the file of the snapshot of the named request. It
+// doesn't end in .yaml so it isn't loaded as configuration.
+func snapshotPath(config, name string) string {
+	return filepath.Join(config, SnapshotDir, filepath.FromSlash(name)+".snap")
+}
+
+func loadSnapshot(path string) (*Snapshot, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s := &Snapshot{}
+	if err := yaml.Unmarshal(buf, s); err != nil {
+		return nil, fmt.Errorf("parsing snapshot: %v", err)
+	}
+	return s, nil
+}
+
+func saveSnapshot(path string, s Snapshot) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0770); err != nil {
+		return fmt.Errorf("creating snapshot folder: %v", err)
+	}
+	buf, err := yaml.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("marshalling snapshot: %v", err)
+	}
+	if err := ioutil.WriteFile(path, buf, 0660); err != nil {
+		return fmt.Errorf("writing snapshot: %v", err)
+	}
+	return nil
+}
+
+// Equal determines if the snapshots are the same.
+func (s Snapshot) Equal(o Snapshot) bool {
+	return s.Status == o.Status && s.Body == o.Body && len(diffMaps(s.Headers, o.Headers, nil)) == 0
+}
+
+// printSnapshotDiff prints how the response differs from the
+// snapshot.
+func printSnapshotDiff(old, cur Snapshot) {
+	if old.Status != cur.Status {
+		Difference{Kind: DiffChanged, Path: "status", Old: old.Status, New: cur.Status}.Print()
+	}
+	for _, d := range diffMaps(old.Headers, cur.Headers, nil) {
+		d.Print()
+	}
+	if old.Body != cur.Body {
+		diffBodies(cur.Headers["Content-Type"], old.Body, cur.Body)
+	}
+}
+
+func requestsnapshot(c *cli.Context, cfg *Config, env Environment) error {
+	if !c.Args().Present() {
+		return cli.Exit(color.Red.Sprintf("snapshot expects at least one request name"), -1)
+	}
+
+	failed := 0
+	for x := 0; x < c.Args().Len(); x++ {
+		name := c.Args().Get(x)
+
+		// We only want to show how it compares, not the response.
+		color.SetOutput(ioutil.Discard)
+		result, err := execute(c, cfg, env, name)
+		color.ResetOutput()
+		if err != nil {
+			return err
+		}
+
+		cur, err := newSnapshot(result.Response, result.Request.Snapshot)
+		if err != nil {
+			return cli.Exit(color.Red.Sprintf("snapshot of %v: %v", name, err), -1)
+		}
+		path := snapshotPath(configDir(c), name)
+		old, err := loadSnapshot(path)
+		if err != nil && !os.IsNotExist(err) {
+			return cli.Exit(color.Red.Sprintf("loading snapshot of %v: %v", name, err), -1)
+		}
+
+		color.Magenta.Printf("%v: ", name)
+		switch {
+		case old == nil && !c.Bool("update"):
+			color.Red.Printf("no snapshot (use --update to approve this response)\n")
+			failed++
+			continue
+		case old == nil:
+			color.Green.Printf("snapshot saved\n")
+		default:
+			// The ignore rules may have changed since it was saved.
+			o, err := old.normalize(result.Request.Snapshot)
+			if err != nil {
+				return cli.Exit(color.Red.Sprintf("snapshot of %v: %v", name, err), -1)
+			}
+			if o.Equal(cur) {
+				color.Green.Printf("ok\n")
+				continue
+			}
+			if !c.Bool("update") {
+				color.Red.Printf("differs from snapshot\n")
+				printSnapshotDiff(o, cur)
+				failed++
+				continue
+			}
+			color.Green.Printf("snapshot updated\n")
+			printSnapshotDiff(o, cur)
+		}
+
+		if err := saveSnapshot(path, cur); err != nil {
+			return cli.Exit(color.Red.Sprintf("saving snapshot of %v: %v", name, err), -1)
+		}
+	}
+
+	if failed > 0 {
+		return cli.Exit(color.Red.Sprintf("%v of %v requests didn't match their snapshot", failed, c.Args().Len()), 1)
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestNewSnapshot(t *testing.T) {
+	resp := &Response{
+		Status:     "200 OK",
+		StatusCode: 200,
+		Headers: map[string]string{
+			"Date":           "[Mon, 19 Oct 2026 00:00:00 GMT]",
+			"content-length": "[120]",
+			"Content-Type":   "[application/json]",
+			"X-Request-Id":   "[abc]",
+			"Etag":           `["1"]`,
+		},
+		Body: `{"id": 1, "updatedAt": "now", "items": [{"id": 1, "at": "x"}, {"id": 2, "at": "y"}]}`,
+	}
+	tests := []struct {
+		name    string
+		body    string
+		opts    SnapshotOptions
+		headers []string
+		want    string
+		err     string
+	}{
+		{
+			name:    "defaults",
+			headers: []string{"Content-Type", "Etag", "X-Request-Id"},
+			want:    "{\n  \"id\": 1,\n  \"updatedAt\": \"now\",\n  \"items\": [\n    {\n      \"id\": 1,\n      \"at\": \"x\"\n    },\n    {\n      \"id\": 2,\n      \"at\": \"y\"\n    }\n  ]\n}",
+		},
+		{
+			name:    "ignore",
+			opts:    SnapshotOptions{Ignore: []string{"$.updatedAt", "$.items[*].at", "$.missing"}, IgnoreHeaders: []string{"x-request-id", "ETag"}},
+			headers: []string{"Content-Type"},
+			want:    "{\n  \"id\": 1,\n  \"items\": [\n    {\n      \"id\": 1\n    },\n    {\n      \"id\": 2\n    }\n  ]\n}",
+		},
+		{
+			name:    "text",
+			body:    "hello",
+			headers: []string{"Content-Type", "Etag", "X-Request-Id"},
+			want:    "hello",
+		},
+		{name: "ignore text", body: "hello", opts: SnapshotOptions{Ignore: []string{"$.id"}}, err: "ignoring body paths"},
+		{name: "invalid path", opts: SnapshotOptions{Ignore: []string{"$.items["}}, err: "parsing ignore '$.items['"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := *resp
+			if tt.body != "" {
+				r.Body = tt.body
+			}
+			got, err := newSnapshot(&r, tt.opts)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got error %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			headers := []string{}
+			for k := range got.Headers {
+				headers = append(headers, k)
+			}
+			sort.Strings(headers)
+			if !reflect.DeepEqual(headers, tt.headers) {
+				t.Errorf("got headers %v, want %v", headers, tt.headers)
+			}
+			if got.Body != tt.want {
+				t.Errorf("got body\n%v\nwant\n%v", got.Body, tt.want)
+			}
+			if got.Status != "200 OK" || got.StatusCode != 200 {
+				t.Errorf("got status %v %v", got.StatusCode, got.Status)
+			}
+		})
+	}
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	opts := SnapshotOptions{Ignore: []string{"$.at"}}
+	cur, err := newSnapshot(&Response{
+		Status:  "200 OK",
+		Headers: map[string]string{"Content-Type": "[application/json]", "Date": "[today]"},
+		Body:    `{"id": 1, "at": "x"}`,
+	}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := snapshotPath(t.TempDir(), "users/get")
+	if filepath.Ext(path) != ".snap" {
+		t.Errorf("got %v, want a .snap file", path)
+	}
+	if err := saveSnapshot(path, cur); err != nil {
+		t.Fatal(err)
+	}
+	old, err := loadSnapshot(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o, err := old.normalize(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !o.Equal(cur) {
+		t.Errorf("got %+v, want %+v", o, cur)
+	}
+
+	// Only the ignored parts changed.
+	next, err := newSnapshot(&Response{
+		Status:  "200 OK",
+		Headers: map[string]string{"Content-Type": "[application/json]", "Date": "[tomorrow]"},
+		Body:    `{"at": "y", "id": 1}`,
+	}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !o.Equal(next) {
+		t.Errorf("got %+v, want %+v", next, o)
+	}
+
+	// A new ignore applies to the saved snapshot too.
+	saved, err := old.normalize(SnapshotOptions{Ignore: []string{"$.id"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Body != "{}" {
+		t.Errorf("got body %v, want {}", saved.Body)
+	}
+
+	for _, changed := range []Snapshot{
+		{Status: "500 Internal Server Error", Headers: cur.Headers, Body: cur.Body},
+		{Status: cur.Status, Headers: map[string]string{}, Body: cur.Body},
+		{Status: cur.Status, Headers: cur.Headers, Body: "{}"},
+	} {
+		if o.Equal(changed) {
+			t.Errorf("%+v is equal to %+v", changed, o)
+		}
+	}
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gookit/color"
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultStateDir is where the files we generate (responses, raw
+// requests and the history) are kept if no state folder is given. It
+// is relative to the config folder.
+const DefaultStateDir = ".aa/state"
+
+// HistoryDir is the folder in the state folder where the history of
+// responses is kept. The history of every environment is kept
+// together so they can be compared.
+const HistoryDir = "history"
+
+// The suffixes of the files generated for each request.
+var stateSuffixes = []string{"-response.yaml", "-response.raw", "-request.raw"}
+
+// configDir is the folder of the config. The config can be a single
+// file.
+func configDir(c *cli.Context) string {
+	config := c.String("config")
+	if info, err := os.Stat(config); err == nil && !info.IsDir() {
+		return filepath.Dir(config)
+	}
+	return config
+}
+
+// stateRoot is the folder the state of every environment is kept in.
+func stateRoot(c *cli.Context) string {
+	if s := c.String("state"); s != "" {
+		return s
+	}
+	return filepath.Join(configDir(c), filepath.FromSlash(DefaultStateDir))
+}
+
+// stateDir is the folder the state of the current environment is
+// kept in.
+func stateDir(c *cli.Context) string {
+	return filepath.Join(stateRoot(c), c.String("environment"))
+}
+
+// checkEnvironment makes sure the state of the environment isn't kept
+// in the history folder, which is next to the environments.
+func checkEnvironment(name string) error {
+	first := strings.SplitN(filepath.ToSlash(name), "/", 2)[0]
+	if strings.EqualFold(first, HistoryDir) {
+		return fmt.Errorf("'%v' can't be used as an environment, its state would be kept with the history", name)
+	}
+	return nil
+}
+
+// statePath is the path of a generated file for the named request.
+// Its folder is created if it doesn't exist.
+func statePath(c *cli.Context, name, suffix string) (string, error) {
+	path := filepath.Join(stateDir(c), filepath.FromSlash(name)+suffix)
+	if err := createStateDir(stateRoot(c), filepath.Dir(path)); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// samePath determines if the paths are the same folder. The state can
+// be given relative to a different folder than the config.
+func samePath(a, b string) bool {
+	a, errA := filepath.Abs(a)
+	b, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && a == b
+}
+
+// createStateDir creates a folder in the state root. The root ignores
+// itself so generated files aren't committed with the config.
+func createStateDir(root, dir string) error {
+	if err := os.MkdirAll(dir, 0770); err != nil {
+		return fmt.Errorf("creating state folder: %v", err)
+	}
+	ignore := filepath.Join(root, ".gitignore")
+	if _, err := os.Stat(ignore); os.IsNotExist(err) {
+		err := ioutil.WriteFile(ignore, []byte("# Generated by aa. Responses and history shouldn't be committed.\n*\n"), 0660)
+		if err != nil {
+			return fmt.Errorf("creating state .gitignore: %v", err)
+		}
+	}
+	return nil
+}
+
+// loadState adds the saved responses of the environment to the
+// config. The responses of other environments are kept separately so
+// they are only used when asked for. Responses in the config folder
+// don't belong to an environment, so they are ignored until they are
+// migrated.
+func loadState(c *cli.Context, cfg *Config) error {
+	if len(cfg.Responses) > 0 {
+		fmt.Fprint(os.Stderr, color.Yellow.Sprintf("<responses in %v are ignored; use 'aa state migrate' to move them to an environment>\n", configDir(c)))
+		cfg.Responses = map[string]Response{}
+	}
+
+	responses, err := loadResponses(stateDir(c))
+	if err != nil {
+		return err
+	}
+	for k, v := range responses {
+		cfg.Responses[k] = v
+	}
+
+	cfg.EnvironmentResponses = map[string]map[string]Response{}
+	for name := range cfg.Environments {
+		if name == c.String("environment") {
+			continue
+		}
+		responses, err := loadResponses(filepath.Join(stateRoot(c), name))
+		if err != nil {
+			return err
+		}
+		cfg.EnvironmentResponses[name] = responses
+	}
+	return nil
+}
+
+// loadResponses reads the saved responses in a state folder.
+func loadResponses(dir string) (map[string]Response, error) {
+	responses := map[string]Response{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) && path == dir {
+			return filepath.SkipDir
+		} else if err != nil {
+			return err
+		}
+		if info.IsDir() || !strings.HasSuffix(path, "-response.yaml") {
+			return nil
+		}
+
+		buf, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		nc := &Config{}
+		if err := yaml.Unmarshal(buf, nc); err != nil {
+			return fmt.Errorf("parsing %v: %v", path, err)
+		}
+		for k, v := range nc.Responses {
+			responses[k] = v
+		}
+		return nil
+	})
+	return responses, err
+}
+
+// statemigrate moves generated files from the config folder (where
+// they used to be written) to the state folder of the environment.
+func statemigrate(c *cli.Context) error {
+	if c.String("environment") == "" {
+		return cli.Exit(color.Red.Sprintf("migrate needs the environment to move the files to (--environment)"), -1)
+	}
+	if err := checkEnvironment(c.String("environment")); err != nil {
+		return cli.Exit(color.Red.Sprintf("%v", err), -1)
+	}
+	config := configDir(c)
+	root := stateRoot(c)
+	dir := stateDir(c)
+	moved := 0
+
+	err := filepath.Walk(config, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(config, path)
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			switch {
+			case path == config:
+				return nil
+			case samePath(path, root):
+				return filepath.SkipDir
+			case rel == ".history":
+				// The history is kept for all environments.
+				if err := moveDir(path, filepath.Join(root, HistoryDir), root); err != nil {
+					return err
+				}
+				color.Green.Printf("%v -> %v\n", path, filepath.Join(root, HistoryDir))
+				moved++
+				return filepath.SkipDir
+			case strings.HasPrefix(info.Name(), "."):
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if !isStateFile(path) {
+			return nil
+		}
+		dst := filepath.Join(dir, rel)
+		if err := createStateDir(root, filepath.Dir(dst)); err != nil {
+			return err
+		}
+		if err := moveFile(path, dst); err != nil {
+			return err
+		}
+		color.Green.Printf("%v -> %v\n", path, dst)
+		moved++
+		return nil
+	})
+	if err != nil {
+		return cli.Exit(color.Red.Sprintf("migrating state: %v", err), -1)
+	}
+
+	color.Magenta.Printf("moved %v to %v\n", moved, dir)
+	color.Magenta.Printf("%v ignores itself, but you may want to add it to your .gitignore\n", root)
+	return nil
+}
+
+// isStateFile determines if the file was generated by running a
+// request. Response YAML must only have responses in it.
+func isStateFile(path string) bool {
+	for _, s := range stateSuffixes {
+		if !strings.HasSuffix(path, s) {
+			continue
+		}
+		if !strings.HasSuffix(path, ".yaml") {
+			return true
+		}
+		buf, err := ioutil.ReadFile(path)
+		if err != nil {
+			return false
+		}
+		nc := &Config{}
+		if err := yaml.Unmarshal(buf, nc); err != nil {
+			return false
+		}
+		return len(nc.Responses) > 0 && len(nc.Requests) == 0 && len(nc.Environments) == 0 && len(nc.Preferences) == 0
+	}
+	return false
+}
+
+// moveDir moves the files in src into dst, merging them with what is
+// already there.
+func moveDir(src, dst, root string) error {
+	err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		to := filepath.Join(dst, rel)
+		if err := createStateDir(root, filepath.Dir(to)); err != nil {
+			return err
+		}
+		return moveFile(path, to)
+	})
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(src)
+}
+
+// moveFile renames the file, copying it if it's going to another
+// file system.
+func moveFile(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	in.Close()
+	return os.Remove(src)
+}
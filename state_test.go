@@ -0,0 +1,24 @@
+package main
+
+import "testing"
+
+func TestCheckEnvironment(t *testing.T) {
+	tests := []struct {
+		name string
+		ok   bool
+	}{
+		{"dev", true},
+		{"histories", true},
+		{"team/history", true},
+		{"history", false},
+		{"History", false},
+		{"history/dev", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkEnvironment(tt.name); (err == nil) != tt.ok {
+				t.Errorf("got %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gookit/color"
+)
+
+// Event is a single server-sent event.
+type Event struct {
+	ID    string `yaml:"id,omitempty"`
+	Event string `yaml:"event,omitempty"`
+	Data  string `yaml:"data"`
+	Retry int    `yaml:"retry,omitempty"`
+}
+
+// isEventStream determines if the response is a stream of
+// server-sent events.
+func isEventStream(resp *http.Response) bool {
+	mt, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	return mt == "text/event-stream"
+}
+
+// streamBody prints the body as it arrives and copies it to w. For
+// server-sent events, each event is parsed and printed when it's
+// complete. Otherwise, each line is printed and counts as an event.
+// If limit isn't zero, we stop after that many events.
+func streamBody(r io.Reader, w io.Writer, sse bool, limit int) ([]Event, error) {
+	br := bufio.NewReader(r)
+	events := []Event{}
+	count := 0
+	cur := Event{}
+	data := []string{}
+
+	for limit == 0 || count < limit {
+		line, err := br.ReadString('\n')
+		w.Write([]byte(line))
+
+		if !sse {
+			if line != "" {
+				color.Green.Printf("%v\n", strings.TrimRight(line, "\r\n"))
+				count++
+			}
+		} else if l := strings.TrimRight(line, "\r\n"); l == "" && line != "" {
+			// A blank line dispatches the event.
+			if len(data) > 0 {
+				cur.Data = strings.Join(data, "\n")
+				events = append(events, cur)
+				printEvent(cur)
+				count++
+			}
+			cur, data = Event{ID: cur.ID}, []string{}
+		} else if !strings.HasPrefix(l, ":") {
+			field, value := l, ""
+			if i := strings.Index(l, ":"); i >= 0 {
+				field, value = l[:i], strings.TrimPrefix(l[i+1:], " ")
+			}
+			switch field {
+			case "id":
+				cur.ID = value
+			case "event":
+				cur.Event = value
+			case "data":
+				data = append(data, value)
+			case "retry":
+				cur.Retry, _ = strconv.Atoi(value)
+			}
+		}
+
+		if err == io.EOF {
+			return events, nil
+		} else if err != nil {
+			return events, err
+		}
+	}
+	return events, nil
+}
+
+func printEvent(e Event) {
+	name := e.Event
+	if name == "" {
+		name = "message"
+	}
+	color.Magenta.Printf("[%v", name)
+	if e.ID != "" {
+		color.Magenta.Printf(" #%v", e.ID)
+	}
+	color.Magenta.Printf("] ")
+	color.Green.Printf("%v\n", e.Data)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestStreamBody(t *testing.T) {
+	tests := []struct {
+		name  string
+		body  string
+		sse   bool
+		limit int
+		want  []Event
+		saved string
+	}{
+		{
+			name: "events",
+			body: "data: a\n\nevent: update\ndata: b\n\n",
+			sse:  true,
+			want: []Event{{Data: "a"}, {Event: "update", Data: "b"}},
+		},
+		{
+			name: "multi-line data",
+			body: "data: a\ndata:b\ndata\ndata:  c\n\n",
+			sse:  true,
+			want: []Event{{Data: "a\nb\n\n c"}},
+		},
+		{
+			name: "id and retry",
+			body: "id: 1\nretry: 3000\ndata: a\n\ndata: b\n\nid\nretry: x\ndata: c\n\n",
+			sse:  true,
+			want: []Event{{ID: "1", Retry: 3000, Data: "a"}, {ID: "1", Data: "b"}, {Data: "c"}},
+		},
+		{
+			name: "comments",
+			body: ": ping\n\n:data: a\ndata: b\n: c\n\n",
+			sse:  true,
+			want: []Event{{Data: "b"}},
+		},
+		{
+			name: "crlf",
+			body: "event: a\r\ndata: b\r\n\r\n",
+			sse:  true,
+			want: []Event{{Event: "a", Data: "b"}},
+		},
+		{
+			name: "no data",
+			body: "event: a\n\nid: 2\n\ndata: b\n\n",
+			sse:  true,
+			want: []Event{{ID: "2", Data: "b"}},
+		},
+		{
+			name: "unknown fields",
+			body: "foo: bar\ndata: a\n\n",
+			sse:  true,
+			want: []Event{{Data: "a"}},
+		},
+		{
+			name: "incomplete",
+			body: "data: a\n\ndata: b\n",
+			sse:  true,
+			want: []Event{{Data: "a"}},
+		},
+		{
+			name:  "max events",
+			body:  "data: a\n\ndata: b\n\ndata: c\n\n",
+			sse:   true,
+			limit: 2,
+			want:  []Event{{Data: "a"}, {Data: "b"}},
+			saved: "data: a\n\ndata: b\n\n",
+		},
+		{
+			name: "lines",
+			body: "a\nb\n",
+			want: []Event{},
+		},
+		{
+			name:  "max lines",
+			body:  "a\nb\nc\n",
+			limit: 2,
+			want:  []Event{},
+			saved: "a\nb\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &bytes.Buffer{}
+			got, err := streamBody(strings.NewReader(tt.body), w, tt.sse, tt.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+			saved := tt.saved
+			if saved == "" {
+				saved = tt.body
+			}
+			if w.String() != saved {
+				t.Errorf("got body %q, want %q", w.String(), saved)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"crypto/tls"
+	"net/http/httptrace"
+	"time"
+)
+
+// Timings break down where the time of a request went. Each phase is
+// only set if it happened (e.g. reused connections have no DNS or
+// connect time).
+type Timings struct {
+	DNS       time.Duration `yaml:"dns,omitempty"`
+	Connect   time.Duration `yaml:"connect,omitempty"`
+	TLS       time.Duration `yaml:"tls,omitempty"`
+	FirstByte time.Duration `yaml:"first-byte,omitempty"`
+	Total     time.Duration `yaml:"total"`
+}
+
+// newTimingTrace returns a trace that fills in the timings of a
+// request that started at start. The total isn't set.
+func newTimingTrace(t *Timings, start time.Time) *httptrace.ClientTrace {
+	var dnsStart, connectStart, connectDone time.Time
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			dnsStart = time.Now()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.DNS = time.Since(dnsStart)
+		},
+		ConnectStart: func(string, string) {
+			connectStart = time.Now()
+		},
+		ConnectDone: func(string, string, error) {
+			connectDone = time.Now()
+			t.Connect = connectDone.Sub(connectStart)
+		},
+		// Our dialer does the handshake before the transport reports
+		// it, so we measure from when the connection was made.
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			if t.TLS == 0 && !connectDone.IsZero() {
+				t.TLS = time.Since(connectDone)
+			}
+		},
+		GotFirstResponseByte: func() {
+			t.FirstByte = time.Since(start)
+		},
+	}
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"reflect"
+	"time"
+
+	"github.com/gookit/color"
+	"github.com/gorilla/websocket"
+	"github.com/urfave/cli/v2"
+)
+
+// DefaultWebSocketTimeout is how long we wait for an expected
+// message if the step doesn't give a timeout.
+const DefaultWebSocketTimeout = 10 * time.Second
+
+// WebSocketStep is a single step of a websocket exchange. If both
+// are given, the message is sent before waiting for the expected
+// reply. Messages that don't match the expected reply are recorded
+// but otherwise ignored. If the expected value is JSON, the reply
+// matches if it contains all of the expected values.
+type WebSocketStep struct {
+	Send    string        `yaml:"send,omitempty"`
+	Expect  string        `yaml:"expect,omitempty"`
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+}
+
+// WebSocketMessage is a message in the transcript of a websocket
+// exchange.
+type WebSocketMessage struct {
+	When      time.Time `yaml:"when"`
+	Direction string    `yaml:"direction"`
+	Data      string    `yaml:"data"`
+}
+
+// runWebSocket connects and runs the steps of the request. If a step
+// fails after connecting, the response has the transcript up to the
+// failure and the error is returned with it.
+func runWebSocket(ctx *cli.Context, name string, r Request, opts TransportOptions, prefs map[string]string) (*Response, error) {
+	in, out, err := createRawFiles(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+	defer out.Close()
+
+	opts.TLS, err = newTLSConfig(prefs, r.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	// Setup the URL
+	u, err := url.Parse(r.URL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing url: %v", err)
+	}
+	switch u.Scheme {
+	case "http":
+		u.Scheme = "ws"
+	case "https":
+		u.Scheme = "wss"
+	case "unix":
+		opts.Socket = unixSocketURL(u)
+		u.Scheme = "ws"
+		color.Blue.Printf("<unix socket '%v'>\n", opts.Socket)
+	}
+	q := u.Query()
+	for k, v := range r.Query {
+		q.Add(k, v)
+	}
+	u.RawQuery = q.Encode()
+	color.Blue.Printf("%v %v\n", http.MethodGet, u)
+
+	// Create Headers
+	header := http.Header{}
+	for k, v := range r.Headers {
+		header.Add(k, v)
+		color.Blue.Printf("%v: %v\n", k, v)
+	}
+	addAuthentication(header, r.Authentication)
+	color.Blue.Printf("\n")
+
+	// Connect
+	d := NewHelperDialer(in, out, opts)
+	dialer := &websocket.Dialer{
+		NetDialContext:   d.DialContext,
+		HandshakeTimeout: 30 * time.Second,
+	}
+	proxy, err := websocketProxy(u, opts)
+	if err != nil {
+		return nil, fmt.Errorf("finding proxy: %v", err)
+	}
+	if proxy != nil {
+		// The TLS handshake is done in the tunnel through the proxy.
+		dialer.Proxy = http.ProxyURL(proxy)
+		dialer.TLSClientConfig = opts.TLS
+	} else {
+		dialer.NetDialTLSContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return d.DialTLSContext(ctx, network, addr, opts.TLS)
+		}
+	}
+
+	start := time.Now()
+	conn, resp, err := dialer.Dial(u.String(), header)
+	if err != nil {
+		return nil, fmt.Errorf("connecting: %v", err)
+	}
+	defer conn.Close()
+
+	color.Green.Printf("%v %v\n", resp.Proto, resp.Status)
+	for k, v := range resp.Header {
+		color.Green.Printf("%v: %v\n", k, v)
+	}
+	color.Green.Printf("\n")
+
+	response := &Response{
+		Status:     resp.Status,
+		StatusCode: resp.StatusCode,
+		Protocol:   resp.Proto,
+		Headers:    map[string]string{},
+	}
+	for k, v := range resp.Header {
+		response.setHeader(k, v)
+	}
+
+	// Read messages in the background so we can time out waiting
+	// for them. Closing done stops the reader if we return early.
+	msgs := make(chan string)
+	done := make(chan struct{})
+	defer close(done)
+	var readErr error
+	go func() {
+		defer close(msgs)
+		for {
+			_, m, err := conn.ReadMessage()
+			if err != nil {
+				readErr = err
+				return
+			}
+			select {
+			case msgs <- string(m):
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	finish := func() *Response {
+		duration := time.Since(start)
+		color.Magenta.Printf("\nduration: %v\n", duration)
+		color.Magenta.Printf("messages: %v\n", len(response.Messages))
+
+		response.When = time.Now()
+		response.Duration = duration
+		response.Size = int64(len(response.Body))
+		response.Timings.Total = response.Duration
+		display, err := formatBody(ctx, "application/json", []byte(response.Body))
+		if err != nil {
+			color.Red.Printf("<%v>\n", err)
+		}
+		response.display = string(display)
+		return response
+	}
+
+	for _, step := range r.WebSocket {
+		if step.Send != "" {
+			if err := conn.WriteMessage(websocket.TextMessage, []byte(step.Send)); err != nil {
+				return finish(), fmt.Errorf("sending message: %v", err)
+			}
+			response.Messages = append(response.Messages, WebSocketMessage{When: time.Now(), Direction: "sent", Data: step.Send})
+			color.Blue.Printf("> %v\n", step.Send)
+		}
+
+		if step.Expect == "" {
+			continue
+		}
+
+		timeout := step.Timeout
+		if timeout == 0 {
+			timeout = DefaultWebSocketTimeout
+		}
+		timer := time.NewTimer(timeout)
+		for matched := false; !matched; {
+			select {
+			case m, ok := <-msgs:
+				if !ok {
+					timer.Stop()
+					return finish(), fmt.Errorf("connection closed waiting for '%v': %v", step.Expect, readErr)
+				}
+				response.Messages = append(response.Messages, WebSocketMessage{When: time.Now(), Direction: "received", Data: m})
+				response.Body = m
+				color.Green.Printf("< %v\n", m)
+				matched = matchMessage(step.Expect, m)
+			case <-timer.C:
+				return finish(), fmt.Errorf("timed out after %v waiting for '%v'", timeout, step.Expect)
+			}
+		}
+		timer.Stop()
+	}
+
+	conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	return finish(), nil
+}
+
+// matchMessage determines if the message matches the expected
+// value. JSON values only need to contain what was expected.
+// Anything else must match exactly.
+func matchMessage(expect, m string) bool {
+	var e, v interface{}
+	if err := json.Unmarshal([]byte(expect), &e); err != nil {
+		return expect == m
+	}
+	if err := json.Unmarshal([]byte(m), &v); err != nil {
+		return false
+	}
+	return matchJSON(e, v)
+}
+
+func matchJSON(e, v interface{}) bool {
+	switch e := e.(type) {
+	case map[string]interface{}:
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for k, ev := range e {
+			if mv, ok := m[k]; !ok || !matchJSON(ev, mv) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		a, ok := v.([]interface{})
+		if !ok || len(a) != len(e) {
+			return false
+		}
+		for x := range e {
+			if !matchJSON(e[x], a[x]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return reflect.DeepEqual(e, v)
+	}
+}
+
+// websocketProxy is the proxy for the websocket URL. Like HTTP
+// requests, the environment variables (HTTPS_PROXY, NO_PROXY) are used
+// when the environment of the config doesn't have a proxy.
+func websocketProxy(u *url.URL, opts TransportOptions) (*url.URL, error) {
+	if opts.Socket != "" {
+		return nil, nil
+	}
+	proxy := http.ProxyFromEnvironment
+	if opts.Proxy != nil {
+		proxy = opts.Proxy.Proxy
+	}
+	scheme := "http"
+	if u.Scheme == "wss" {
+		scheme = "https"
+	}
+	return proxy(&http.Request{URL: &url.URL{Scheme: scheme, Host: u.Host}})
+}